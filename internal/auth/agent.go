@@ -1,15 +1,26 @@
 package auth
 
 import (
+	"log/slog"
 	"net"
 	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
 )
 
 // AgentAuthenticator provides SSH authentication via the SSH agent.
-type AgentAuthenticator struct{}
+type AgentAuthenticator struct {
+	Socket string          // Optional: agent socket path. If empty, uses $SSH_AUTH_SOCK.
+	Keys   []ssh.PublicKey // Optional: only offer these keys from the agent (IdentitiesOnly)
+
+	mu   sync.Mutex
+	conn net.Conn // Open while a handshake uses the agent's keys
+}
 
 func (a *AgentAuthenticator) Name() string {
 	return "agent"
@@ -20,7 +31,7 @@ func (a *AgentAuthenticator) Priority() int {
 }
 
 func (a *AgentAuthenticator) IsAvailable() bool {
-	conn, err := getSocketConn()
+	conn, err := a.dial()
 	if err != nil {
 		return false
 	}
@@ -30,16 +41,87 @@ func (a *AgentAuthenticator) IsAvailable() bool {
 }
 
 func (a *AgentAuthenticator) GetAuthMethod() (ssh.AuthMethod, error) {
-	conn, err := getSocketConn()
+	return ssh.PublicKeysCallback(a.Signers), nil
+}
+
+// Signers returns the agent's keys, limited to Keys if set. Certificates
+// come first, since servers trusting a CA may not know the plain keys,
+// and expired ones are left out. The signers use the agent connection
+// until Close.
+func (a *AgentAuthenticator) Signers() ([]ssh.Signer, error) {
+	conn, err := a.open()
 	if err != nil {
 		return nil, err
 	}
 
-	agentClient := agent.NewClient(conn)
-	return ssh.PublicKeysCallback(agentClient.Signers), nil
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		return nil, err
+	}
+
+	var certs, keys []ssh.Signer
+	for _, signer := range signers {
+		if !a.allowed(signer.PublicKey()) {
+			continue
+		}
+		cert, ok := signer.PublicKey().(*ssh.Certificate)
+		if !ok {
+			keys = append(keys, signer)
+			continue
+		}
+		if err := checkCertificate(strconv.Quote(cert.KeyId), cert, time.Now()); err != nil {
+			slog.Warn("Skipping agent key", "err", err)
+			continue
+		}
+		certs = append(certs, signer)
+	}
+	return append(certs, keys...), nil
 }
 
-func getSocketConn() (net.Conn, error) {
-	socket := os.Getenv("SSH_AUTH_SOCK")
-	return net.Dial("unix", socket)
+// allowed reports whether a key, or the key a certificate is for, may be offered
+func (a *AgentAuthenticator) allowed(pub ssh.PublicKey) bool {
+	if len(a.Keys) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(a.Keys, func(key ssh.PublicKey) bool {
+		return keysEqual(certKey(pub), key)
+	})
+}
+
+// open returns the agent connection, dialing it if there isn't one
+func (a *AgentAuthenticator) open() (net.Conn, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.conn == nil {
+		conn, err := a.dial()
+		if err != nil {
+			return nil, err
+		}
+		a.conn = conn
+	}
+	return a.conn, nil
+}
+
+// Close closes the agent connection once a handshake is done with it.
+// The next handshake dials the agent again.
+func (a *AgentAuthenticator) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.conn == nil {
+		return nil
+	}
+	err := a.conn.Close()
+	a.conn = nil
+	return err
+}
+
+// dial connects to the agent socket
+func (a *AgentAuthenticator) dial() (net.Conn, error) {
+	socket := a.Socket
+	if socket == "" {
+		socket = os.Getenv("SSH_AUTH_SOCK")
+	}
+	return net.Dial("unix", expandPath(socket))
 }
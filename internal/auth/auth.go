@@ -2,8 +2,9 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
-	"sort"
+	"slices"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -16,122 +17,230 @@ type Authenticator interface {
 	Priority() int // Lower = higher priority (tried first)
 }
 
-func GetAllAuthenticators() []Authenticator {
+// newAuthenticators returns every authenticator, asking for secrets through p
+func newAuthenticators(p Prompter) []Authenticator {
 	authenticators := []Authenticator{
 		&AgentAuthenticator{},
-		&PublicKeyAuthenticator{},
-		&PasswordAuthenticator{},
+		&PublicKeyAuthenticator{Prompter: p},
+		&KeyboardInteractiveAuthenticator{Prompter: p},
+		&PasswordAuthenticator{Prompter: p},
 	}
 	return authenticators
 }
 
-func GetAvailableAuthenticators() []Authenticator {
-	all := GetAllAuthenticators()
-	var available []Authenticator
-	for _, auth := range all {
-		if auth.IsAvailable() {
-			available = append(available, auth)
+// methodNames are the auth methods that can be configured, in the
+// order "auto" tries them
+var methodNames = []string{"agent", "publickey", "keyboard-interactive", "password"}
+
+// ValidateMethods checks that every method is known. "auto" can only be
+// used on its own.
+func ValidateMethods(methods []string) error {
+	for _, m := range methods {
+		if m == "auto" || m == "" {
+			if len(methods) > 1 {
+				return fmt.Errorf("auth method %q can't be combined with other methods", m)
+			}
+			continue
+		}
+		if !slices.Contains(methodNames, m) {
+			return fmt.Errorf("unknown authentication method: %q", m)
 		}
 	}
-	return available
+	return nil
 }
 
-func GetAvailableAuthMethods() ([]ssh.AuthMethod, error) {
-	authenticators := GetAvailableAuthenticators()
-	if len(authenticators) == 0 {
-		return nil, fmt.Errorf("no authentication methods available")
+// Options selects and configures the auth methods for a connection
+type Options struct {
+	Methods        []string // Methods to try in order; empty or "auto" tries every available one
+	KeyPaths       []string // Private keys for publickey auth, instead of the default locations
+	IdentitiesOnly bool     // Only offer the keys in KeyPaths, also from the agent
+	AgentSocket    string   // SSH agent socket, instead of $SSH_AUTH_SOCK
+	CertFiles      []string // Certificates for the keys, in addition to <key>-cert.pub
+
+	// Trace is told about each method as the server is asked to accept
+	// it, for the tunnel's log (optional)
+	Trace func(msg string)
+}
+
+// trace reports a method being tried, if anyone is listening
+func (o Options) trace(format string, args ...any) {
+	if o.Trace != nil {
+		o.Trace(fmt.Sprintf(format, args...))
 	}
+}
 
-	// Sort by priority (lower = first)
-	sort.Slice(authenticators, func(a, b int) bool {
-		return authenticators[a].Priority() < authenticators[b].Priority()
-	})
+// Identity describes the credentials the options authenticate with, so
+// that connections made with different keys, agents or methods are told
+// apart
+func (o Options) Identity() string {
+	return fmt.Sprintf("methods=%q keys=%q identities-only=%t agent=%q certs=%q",
+		o.Methods, o.KeyPaths, o.IdentitiesOnly, o.AgentSocket, o.CertFiles)
+}
 
-	// Collect auth methods
-	var methods []ssh.AuthMethod
-	for _, auth := range authenticators {
-		m, err := auth.GetAuthMethod()
-		if err != nil {
-			// Silent failure in auto mode - skip to next
-			continue
+// GetAuthMethods returns the SSH auth methods for opts, skipping methods
+// that aren't available (e.g. no agent running). Agent and key file auth
+// are combined into a single publickey method, since the SSH client
+// doesn't retry a method name once it has failed. An expired key
+// certificate is an error, so it's reported before connecting.
+// Passwords and key passphrases are requested through p. The returned
+// release func closes the agent connection; call it once each handshake
+// using the methods has finished.
+func GetAuthMethods(opts Options, p Prompter) ([]ssh.AuthMethod, func(), error) {
+	if err := ValidateMethods(opts.Methods); err != nil {
+		return nil, nil, err
+	}
+	names := opts.Methods
+	if len(names) == 0 || names[0] == "auto" || names[0] == "" {
+		names = methodNames
+	}
+
+	var (
+		methods []ssh.AuthMethod
+		sources []signerSource
+		keysAt  = -1 // Index of the combined publickey method
+		agents  []*AgentAuthenticator
+		errs    []error
+	)
+	for _, name := range names {
+		switch name {
+		case "agent", "publickey":
+			keys, agent, err := opts.keySources(name, p)
+			var certErr *CertificateError
+			if errors.As(err, &certErr) {
+				return nil, nil, err
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			sources = append(sources, keys...)
+			if agent != nil {
+				agents = append(agents, agent)
+			}
+			if keysAt < 0 {
+				keysAt = len(methods)
+				methods = append(methods, nil)
+			}
+		default:
+			m, err := opts.method(name, tracingPrompter{p: promptOrTerminal(p), trace: func() { opts.trace("Trying %s", name) }})
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			methods = append(methods, m)
 		}
-		methods = append(methods, m)
+	}
+
+	if keysAt >= 0 {
+		methods[keysAt] = ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			var signers []ssh.Signer
+			for _, source := range sources {
+				// An unusable source shouldn't stop the others being offered
+				if s, err := source(); err == nil {
+					signers = append(signers, s...)
+				}
+			}
+			opts.trace("Trying publickey with %d keys", len(signers))
+			return signers, nil
+		})
 	}
 
 	if len(methods) == 0 {
-		return nil, fmt.Errorf("no authentication methods could be initialized")
+		if len(errs) == 0 {
+			return nil, nil, fmt.Errorf("no authentication methods available")
+		}
+		return nil, nil, errors.Join(errs...)
 	}
 
-	return methods, nil
+	release := func() {
+		for _, agent := range agents {
+			_ = agent.Close()
+		}
+	}
+	return methods, release, nil
 }
 
-// GetAuthMethodsByName returns SSH auth methods based on the specified method.
-// If method is "auto", returns all available methods sorted by priority.
-// Otherwise, returns only the specified method.
-func GetAuthMethodsByName(method string) ([]ssh.AuthMethod, error) {
-	methods, err := GetAvailableAuthMethods()
+// signerSource returns the keys offered for publickey auth
+type signerSource func() ([]ssh.Signer, error)
+
+// keySources returns where the agent or publickey method gets its keys,
+// and the agent if that is where they come from
+func (o Options) keySources(name string, p Prompter) ([]signerSource, *AgentAuthenticator, error) {
+	if name == "publickey" {
+		sources, err := o.keyFiles(p)
+		return sources, nil, err
+	}
+	agent, err := o.agent()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return []signerSource{agent.Signers}, agent, nil
+}
 
-	if method == "" || method == "auto" {
-		return methods, nil
+// agent returns the agent authenticator, limited to the configured keys
+// with IdentitiesOnly
+func (o Options) agent() (*AgentAuthenticator, error) {
+	agent := &AgentAuthenticator{Socket: o.AgentSocket}
+	if !agent.IsAvailable() {
+		return nil, fmt.Errorf("authentication method %q is not available", "agent")
+	}
+	if !o.IdentitiesOnly {
+		return agent, nil
 	}
 
-	return getAuthMethodByName(method)
-}
-
-func getAuthMethodByName(method string) ([]ssh.AuthMethod, error) {
-	authenticators := GetAllAuthenticators()
-	for _, auth := range authenticators {
-		if auth.Name() == method {
-			if !auth.IsAvailable() {
-				return nil, fmt.Errorf("authentication method %q is not available", method)
-			}
-			m, err := auth.GetAuthMethod()
-			if err != nil {
-				return nil, fmt.Errorf("failed to initialize %q auth: %w", method, err)
-			}
-			return []ssh.AuthMethod{m}, nil
+	for _, keyPath := range o.KeyPaths {
+		if pub, err := publicKeyFor(keyPath); err == nil {
+			agent.Keys = append(agent.Keys, pub)
 		}
 	}
-	return nil, fmt.Errorf("unknown authentication method: %q", method)
+	if len(agent.Keys) == 0 {
+		return nil, fmt.Errorf("identities_only is set but no configured key has a public key")
+	}
+	return agent, nil
 }
 
-// GetAuthMethodsWithIdentity returns SSH auth methods, using the provided identity files
-// from SSH config if available. Identity files take priority over default key locations.
-// If identityFiles is empty, falls back to default behavior.
-func GetAuthMethodsWithIdentity(method string, identityFiles []string) ([]ssh.AuthMethod, error) {
-	// If no identity files specified, use default behavior
-	if len(identityFiles) == 0 {
-		return GetAuthMethodsByName(method)
+// keyFiles returns a signer source per usable key file: the configured
+// keys, or the default keys if none are configured. Keys with a
+// certificate offer it first.
+func (o Options) keyFiles(p Prompter) ([]signerSource, error) {
+	keyPaths := o.KeyPaths
+	if len(keyPaths) == 0 {
+		keyPaths = defaultKeyPaths
 	}
 
-	var methods []ssh.AuthMethod
-
-	// Always try SSH agent first (if available) - it may have the keys loaded
-	agent := &AgentAuthenticator{}
-	if agent.IsAvailable() {
-		if m, err := agent.GetAuthMethod(); err == nil {
-			methods = append(methods, m)
+	var sources []signerSource
+	for _, keyPath := range keyPaths {
+		pk := &PublicKeyAuthenticator{KeyPath: keyPath, Prompter: p}
+		if !pk.IsAvailable() {
+			continue
 		}
-	}
-
-	// Try each identity file from SSH config
-	for _, keyPath := range identityFiles {
-		pk := &PublicKeyAuthenticator{KeyPath: keyPath}
-		if pk.IsAvailable() {
-			if m, err := pk.GetAuthMethod(); err == nil {
-				methods = append(methods, m)
-			}
+		cert, err := certificateFor(keyPath, o.CertFiles)
+		if err != nil {
+			return nil, err
 		}
+		pk.Cert = cert
+		sources = append(sources, pk.Signers)
 	}
-
-	// If specific method requested and we have methods, return them
-	if len(methods) > 0 {
-		return methods, nil
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("authentication method %q is not available: no private key found", "publickey")
 	}
+	return sources, nil
+}
 
-	// Fall back to default auth methods if SSH config identity files didn't work
-	return GetAuthMethodsByName(method)
+// method returns one of the methods that don't use keys
+func (o Options) method(name string, p Prompter) (ssh.AuthMethod, error) {
+	for _, a := range newAuthenticators(p) {
+		if a.Name() != name {
+			continue
+		}
+		if !a.IsAvailable() {
+			return nil, fmt.Errorf("authentication method %q is not available", name)
+		}
+		m, err := a.GetAuthMethod()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize %q auth: %w", name, err)
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown authentication method: %q", name)
 }
@@ -1,9 +1,27 @@
 package auth
 
-import "testing"
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
 
 func TestGetAuthenticators(t *testing.T) {
-	authenticators := GetAllAuthenticators()
+	authenticators := newAuthenticators(nil)
 	if len(authenticators) == 0 {
 		t.Error("No authenticators defined")
 	}
@@ -12,9 +30,297 @@ func TestGetAuthenticators(t *testing.T) {
 func TestUniqueAuthenticatorPriority(t *testing.T) {
 	seen := make(map[int]string)
 
-	for _, auth := range GetAllAuthenticators() {
+	authenticators := newAuthenticators(nil)
+	for _, auth := range authenticators {
 		if existing, exists := seen[auth.Priority()]; exists {
 			t.Errorf("priority %d used by both %s and %s", auth.Priority(), existing, auth.Name())
 		}
+		seen[auth.Priority()] = auth.Name()
+	}
+
+	// "auto" tries the methods in priority order
+	slices.SortFunc(authenticators, func(a, b Authenticator) int { return a.Priority() - b.Priority() })
+	var names []string
+	for _, auth := range authenticators {
+		names = append(names, auth.Name())
+	}
+	if want := []string{"agent", "publickey", "keyboard-interactive", "password"}; !slices.Equal(names, want) || !slices.Equal(methodNames, want) {
+		t.Errorf("priority order = %v, auto order = %v, want %v", names, methodNames, want)
+	}
+}
+
+func TestValidateMethods(t *testing.T) {
+	tests := []struct {
+		methods []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{"auto"}, false},
+		{[]string{"agent", "password"}, false},
+		{[]string{"auto", "password"}, true},
+		{[]string{"kerberos"}, true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateMethods(tt.methods); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateMethods(%q) error = %v, wantErr %v", tt.methods, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTerminalPrompter_SharedReader(t *testing.T) {
+	// A host key answer read from the shared reader buffers the lines
+	// after it, which the prompter must still see
+	in := bufio.NewReader(strings.NewReader("yes\nalice\nsecret\nhunter2\n"))
+	if line, err := in.ReadString('\n'); err != nil || line != "yes\n" {
+		t.Fatalf("ReadString() = %q, %v", line, err)
+	}
+
+	p := TerminalPrompter{In: in}
+	answers, err := p.Challenge("", "", []string{"User: ", "Password: "}, []bool{true, false})
+	if err != nil {
+		t.Fatalf("Challenge() error = %v", err)
+	}
+	if !slices.Equal(answers, []string{"alice", "secret"}) {
+		t.Errorf("Challenge() = %q, want [alice secret]", answers)
+	}
+
+	if secret, err := p.Secret("Passphrase: "); err != nil || secret != "hunter2" {
+		t.Errorf("Secret() = %q, %v, want hunter2", secret, err)
+	}
+}
+
+// countingPrompter answers secret prompts with a fixed passphrase
+type countingPrompter struct {
+	passphrase string
+	asked      int
+}
+
+func (p *countingPrompter) Secret(string) (string, error) {
+	p.asked++
+	return p.passphrase, nil
+}
+
+func (p *countingPrompter) Challenge(string, string, []string, []bool) ([]string, error) {
+	return nil, errors.New("unexpected challenge")
+}
+
+// writeKey writes a new ed25519 key to dir, encrypted if passphrase is set
+func writeKey(t *testing.T, dir, name, passphrase string) ssh.Signer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block *pem.Block
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestPublicKeyAuthenticator_EncryptedKeyPromptsOnSign(t *testing.T) {
+	dir := t.TempDir()
+	want := writeKey(t, dir, "id_ed25519", "secret")
+
+	p := &countingPrompter{passphrase: "secret"}
+	pk := &PublicKeyAuthenticator{KeyPath: filepath.Join(dir, "id_ed25519"), Prompter: p}
+
+	signers, err := pk.Signers()
+	if err != nil {
+		t.Fatalf("Signers() error = %v", err)
+	}
+	if len(signers) != 1 || !bytes.Equal(signers[0].PublicKey().Marshal(), want.PublicKey().Marshal()) {
+		t.Fatalf("Signers() returned the wrong key")
+	}
+	if p.asked != 0 {
+		t.Fatalf("passphrase asked for before signing")
+	}
+
+	data := []byte("session")
+	sig, err := signers[0].Sign(rand.Reader, data)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if err := want.PublicKey().Verify(data, sig); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if p.asked != 1 {
+		t.Errorf("passphrase asked for %d times, want 1", p.asked)
+	}
+}
+
+// serveAgent serves keyring on a unix socket and counts the connections
+// still open
+func serveAgent(t *testing.T, keyring agent.Agent) (string, *atomic.Int32) {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	var open atomic.Int32
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			open.Add(1)
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				_ = conn.Close()
+				open.Add(-1)
+			}()
+		}
+	}()
+	return socket, &open
+}
+
+func TestOptions_IdentitiesOnlyFiltersAgent(t *testing.T) {
+	dir := t.TempDir()
+	configured := writeKey(t, dir, "id_work", "")
+	writeKey(t, dir, "id_other", "")
+
+	keyring := agent.NewKeyring()
+	for _, name := range []string{"id_work", "id_other"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := ssh.ParseRawPrivateKey(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	socket, _ := serveAgent(t, keyring)
+	opts := Options{AgentSocket: socket, KeyPaths: []string{filepath.Join(dir, "id_work")}}
+
+	a, err := opts.agent()
+	if err != nil {
+		t.Fatalf("agent() error = %v", err)
+	}
+	if got, _ := a.Signers(); len(got) != 2 {
+		t.Errorf("without IdentitiesOnly: %d signers, want 2", len(got))
+	}
+
+	opts.IdentitiesOnly = true
+	a, err = opts.agent()
+	if err != nil {
+		t.Fatalf("agent() error = %v", err)
+	}
+	got, err := a.Signers()
+	if err != nil {
+		t.Fatalf("Signers() error = %v", err)
+	}
+	if len(got) != 1 || !bytes.Equal(got[0].PublicKey().Marshal(), configured.PublicKey().Marshal()) {
+		t.Errorf("with IdentitiesOnly: got %d signers, want only the configured key", len(got))
+	}
+}
+
+func TestGetAuthMethods_ReleaseClosesAgent(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	writeKey(t, dir, "id_agent", "")
+	data, err := os.ReadFile(filepath.Join(dir, "id_agent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.ParseRawPrivateKey(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	socket, open := serveAgent(t, keyring)
+
+	methods, release, err := GetAuthMethods(Options{Methods: []string{"agent"}, AgentSocket: socket}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) { return nil, nil },
+	}
+	serverCfg.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				if sshConn, _, _, err := ssh.NewServerConn(conn, serverCfg); err == nil {
+					_ = sshConn.Wait()
+				}
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	// Handshakes reuse the agent connection until it is released
+	for range 2 {
+		client, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, _, _, err := ssh.NewClientConn(client, l.Addr().String(), &ssh.ClientConfig{
+			User:            "test",
+			Auth:            methods,
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.Close()
+		waitOpen(t, open, 1)
+	}
+
+	release()
+	waitOpen(t, open, 0)
+}
+
+// waitOpen waits for the agent to have want connections open
+func waitOpen(t *testing.T, open *atomic.Int32, want int32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for open.Load() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d agent connections open, want %d", open.Load(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
 }
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultSignerCacheTTL is how long decrypted keys are kept when not configured
+const DefaultSignerCacheTTL = 15 * time.Minute
+
+// signerCache keeps decrypted private keys in memory so encrypted keys
+// don't need their passphrase on every connection
+type signerCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedSigner
+}
+
+type cachedSigner struct {
+	signer  ssh.Signer
+	expires time.Time
+}
+
+var signers = &signerCache{
+	ttl:     DefaultSignerCacheTTL,
+	entries: make(map[string]cachedSigner),
+}
+
+// SetSignerCacheTTL sets how long decrypted keys are cached.
+// A TTL of zero or less disables caching and clears the cache.
+func SetSignerCacheTTL(ttl time.Duration) {
+	signers.mu.Lock()
+	defer signers.mu.Unlock()
+
+	signers.ttl = ttl
+	if ttl <= 0 {
+		signers.entries = make(map[string]cachedSigner)
+	}
+}
+
+// get returns the cached signer for a key path, if present and not expired
+func (c *signerCache) get(keyPath string) (ssh.Signer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[keyPath]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, keyPath)
+		return nil, false
+	}
+	return entry.signer, true
+}
+
+// put caches a decrypted signer for the configured TTL
+func (c *signerCache) put(keyPath string, signer ssh.Signer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 {
+		return
+	}
+	c.entries[keyPath] = cachedSigner{
+		signer:  signer,
+		expires: time.Now().Add(c.ttl),
+	}
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// CertificateError is returned for a certificate that is expired or not
+// valid yet, so it's reported before connecting rather than as a
+// rejected key
+type CertificateError struct {
+	Path        string
+	ValidAfter  time.Time
+	ValidBefore time.Time
+	Expired     bool // false if the certificate is not valid yet
+}
+
+func (e *CertificateError) Error() string {
+	if e.Expired {
+		return fmt.Sprintf("certificate %s expired at %s", e.Path, e.ValidBefore.Format(time.DateTime))
+	}
+	return fmt.Sprintf("certificate %s is not valid until %s", e.Path, e.ValidAfter.Format(time.DateTime))
+}
+
+// checkCertificate returns a *CertificateError if cert isn't valid at now
+func checkCertificate(path string, cert *ssh.Certificate, now time.Time) error {
+	unix := now.Unix()
+	if unix >= 0 && uint64(unix) >= cert.ValidBefore && cert.ValidBefore != ssh.CertTimeInfinity {
+		return &CertificateError{Path: path, ValidBefore: time.Unix(int64(cert.ValidBefore), 0), Expired: true}
+	}
+	if unix < 0 || uint64(unix) < cert.ValidAfter {
+		return &CertificateError{Path: path, ValidAfter: time.Unix(int64(cert.ValidAfter), 0)}
+	}
+	return nil
+}
+
+// readCertificate reads an OpenSSH certificate file, such as id_ed25519-cert.pub
+func readCertificate(path string) (*ssh.Certificate, error) {
+	pub, err := readPublicKey(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read certificate %s: %w", path, err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a certificate", path)
+	}
+	return cert, nil
+}
+
+// certificateFor finds the certificate for a private key: the one in
+// certFiles (from CertificateFile) for the same public key, or
+// <key>-cert.pub next to the key. Returns nil if the key has none, and
+// an error if the certificate can't be used now.
+func certificateFor(keyPath string, certFiles []string) (*ssh.Certificate, error) {
+	keyPath = expandPath(keyPath)
+
+	var candidates []string
+	if len(certFiles) > 0 {
+		if pub, err := publicKeyFor(keyPath); err == nil {
+			for _, path := range certFiles {
+				cert, err := readCertificate(expandPath(path))
+				if err == nil && keysEqual(cert.Key, pub) {
+					candidates = append(candidates, expandPath(path))
+				}
+			}
+		}
+	}
+	candidates = append(candidates, keyPath+"-cert.pub")
+
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		cert, err := readCertificate(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkCertificate(path, cert, time.Now()); err != nil {
+			return nil, err
+		}
+		return cert, nil
+	}
+	return nil, nil
+}
+
+// keysEqual reports whether two public keys are the same key
+func keysEqual(a, b ssh.PublicKey) bool {
+	return string(a.Marshal()) == string(b.Marshal())
+}
+
+// certKey returns the key a certificate certifies, or pub itself if it
+// isn't a certificate
+func certKey(pub ssh.PublicKey) ssh.PublicKey {
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		return cert.Key
+	}
+	return pub
+}
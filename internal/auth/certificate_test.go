@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writeCert signs a user certificate for key with a new CA and writes it to path
+func writeCert(t *testing.T, path string, key ssh.PublicKey, validAfter, validBefore time.Time) {
+	t.Helper()
+
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := &ssh.Certificate{
+		Key:             key,
+		CertType:        ssh.UserCert,
+		KeyId:           "test",
+		ValidPrincipals: []string{"test"},
+		ValidAfter:      uint64(validAfter.Unix()),
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, ssh.MarshalAuthorizedKey(cert), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertificateFor(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "id_ed25519")
+	signer := writeKey(t, dir, "id_ed25519", "")
+
+	cert, err := certificateFor(keyPath, nil)
+	if err != nil || cert != nil {
+		t.Fatalf("certificateFor() without a certificate = %v, %v, want nil, nil", cert, err)
+	}
+
+	now := time.Now()
+	writeCert(t, keyPath+"-cert.pub", signer.PublicKey(), now.Add(-time.Hour), now.Add(time.Hour))
+
+	cert, err = certificateFor(keyPath, nil)
+	if err != nil || cert == nil {
+		t.Fatalf("certificateFor() = %v, %v, want the certificate", cert, err)
+	}
+
+	pk := &PublicKeyAuthenticator{KeyPath: keyPath, Cert: cert}
+	signers, err := pk.Signers()
+	if err != nil {
+		t.Fatalf("Signers() error = %v", err)
+	}
+	if len(signers) != 2 {
+		t.Fatalf("Signers() returned %d signers, want the certificate and the key", len(signers))
+	}
+	if _, ok := signers[0].PublicKey().(*ssh.Certificate); !ok {
+		t.Errorf("Signers()[0] is %T, want the certificate first", signers[0].PublicKey())
+	}
+}
+
+func TestCertificateFor_Expired(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "id_ed25519")
+	signer := writeKey(t, dir, "id_ed25519", "")
+
+	// A certificate given by CertificateFile, not next to the key
+	certPath := filepath.Join(dir, "bastion-cert.pub")
+	expiry := time.Now().Add(-time.Minute).Truncate(time.Second)
+	writeCert(t, certPath, signer.PublicKey(), expiry.Add(-time.Hour), expiry)
+
+	_, err := certificateFor(keyPath, []string{certPath})
+
+	var certErr *CertificateError
+	if !errors.As(err, &certErr) || !certErr.Expired {
+		t.Fatalf("certificateFor() error = %v, want an expired certificate error", err)
+	}
+	if !certErr.ValidBefore.Equal(expiry) {
+		t.Errorf("ValidBefore = %v, want %v", certErr.ValidBefore, expiry)
+	}
+
+	_, _, err = GetAuthMethods(Options{Methods: []string{"publickey", "password"}, KeyPaths: []string{keyPath}, CertFiles: []string{certPath}}, nil)
+	if !errors.As(err, &certErr) {
+		t.Errorf("GetAuthMethods() error = %v, want the expired certificate error", err)
+	}
+}
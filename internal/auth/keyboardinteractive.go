@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// KeyboardInteractiveAuthenticator provides SSH authentication via
+// keyboard-interactive challenges, as used by OTP and 2FA bastions
+type KeyboardInteractiveAuthenticator struct {
+	Prompter Prompter // Answers the server's challenges. Defaults to the terminal.
+}
+
+func (k *KeyboardInteractiveAuthenticator) Name() string {
+	return "keyboard-interactive"
+}
+
+func (k *KeyboardInteractiveAuthenticator) Priority() int {
+	return 3 // After keys, before plain password (same order as OpenSSH)
+}
+
+func (k *KeyboardInteractiveAuthenticator) IsAvailable() bool {
+	// Whether the server offers it is only known during the handshake
+	return true
+}
+
+func (k *KeyboardInteractiveAuthenticator) GetAuthMethod() (ssh.AuthMethod, error) {
+	return ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		// Servers may send empty challenges (e.g. to show a banner or
+		// while waiting on a push notification); answer those silently
+		if len(questions) == 0 {
+			return nil, nil
+		}
+
+		answers, err := promptOrTerminal(k.Prompter).Challenge(name, instruction, questions, echos)
+		if err != nil {
+			return nil, fmt.Errorf("failed to answer challenge: %w", err)
+		}
+
+		return answers, nil
+	}), nil
+}
@@ -2,16 +2,15 @@ package auth
 
 import (
 	"fmt"
-	"os"
 
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/term"
 )
 
 // PasswordAuthenticator provides SSH authentication via password.
 // TODO: Is storing a password in a config a good idea?
 type PasswordAuthenticator struct {
-	Password string // Optional: pre-configured password. If empty, prompts user.
+	Password string   // Optional: pre-configured password. If empty, prompts user.
+	Prompter Prompter // Asks for the password. Defaults to the terminal.
 }
 
 func (p *PasswordAuthenticator) Name() string {
@@ -19,7 +18,7 @@ func (p *PasswordAuthenticator) Name() string {
 }
 
 func (p *PasswordAuthenticator) Priority() int {
-	return 3 // Lowest priority - last resort
+	return 4 // Lowest priority - last resort
 }
 
 func (p *PasswordAuthenticator) IsAvailable() bool {
@@ -34,13 +33,11 @@ func (p *PasswordAuthenticator) GetAuthMethod() (ssh.AuthMethod, error) {
 
 	return ssh.PasswordCallback(func() (string, error) {
 		// Prompt for password
-		fmt.Print("Enter SSH password: ")
-		password, err := term.ReadPassword(int(os.Stdin.Fd()))
-		fmt.Println() // newline after password input
+		password, err := promptOrTerminal(p.Prompter).Secret("Enter SSH password: ")
 		if err != nil {
 			return "", fmt.Errorf("failed to read password: %w", err)
 		}
 
-		return string(password), nil
+		return password, nil
 	}), nil
 }
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Prompter asks the user for input needed during authentication.
+// The daemon supplies one that relays prompts to an attached client.
+type Prompter interface {
+	// Secret asks for a value that must not be echoed, such as a
+	// password or key passphrase
+	Secret(prompt string) (string, error)
+
+	// Challenge answers a keyboard-interactive challenge. echos reports,
+	// per question, whether the answer may be shown while typing.
+	Challenge(name, instruction string, questions []string, echos []bool) ([]string, error)
+}
+
+// TerminalPrompter reads input from the process's terminal
+type TerminalPrompter struct {
+	// In reads stdin if set. Share it with anything else reading stdin,
+	// so input it has buffered isn't lost between prompts.
+	In *bufio.Reader
+}
+
+// Secret prints the prompt and reads a line without echoing it
+func (t TerminalPrompter) Secret(prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	// Echo can only be turned off for input not read yet
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) && (t.In == nil || t.In.Buffered() == 0) {
+		secret, err := term.ReadPassword(fd)
+		fmt.Println() // newline after password input
+		if err != nil {
+			return "", err
+		}
+		return string(secret), nil
+	}
+
+	secret, err := t.readLine()
+	fmt.Println()
+	return secret, err
+}
+
+// Challenge prints the challenge and reads an answer for each question
+func (t TerminalPrompter) Challenge(name, instruction string, questions []string, echos []bool) ([]string, error) {
+	if name != "" {
+		fmt.Println(name)
+	}
+	if instruction != "" {
+		fmt.Println(instruction)
+	}
+
+	answers := make([]string, len(questions))
+	for i, q := range questions {
+		var err error
+		if echos[i] {
+			fmt.Print(q)
+			answers[i], err = t.readLine()
+		} else {
+			answers[i], err = t.Secret(q)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return answers, nil
+}
+
+// readLine reads a line from In, or from stdin if In isn't set
+func (t TerminalPrompter) readLine() (string, error) {
+	if t.In == nil {
+		return readLine(os.Stdin)
+	}
+	line, err := t.In.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readLine reads a line one byte at a time so no input is buffered
+// past the newline
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n == 1 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				break
+			}
+			return "", err
+		}
+	}
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
+
+// promptOrTerminal returns p, or a TerminalPrompter if p is nil
+func promptOrTerminal(p Prompter) Prompter {
+	if p == nil {
+		return TerminalPrompter{}
+	}
+	return p
+}
+
+// tracingPrompter calls trace before each prompt, so methods that only
+// involve the user once the server asks can be logged when they're tried
+type tracingPrompter struct {
+	p     Prompter
+	trace func()
+}
+
+func (t tracingPrompter) Secret(prompt string) (string, error) {
+	t.trace()
+	return t.p.Secret(prompt)
+}
+
+func (t tracingPrompter) Challenge(name, instruction string, questions []string, echos []bool) ([]string, error) {
+	t.trace()
+	return t.p.Challenge(name, instruction, questions, echos)
+}
@@ -1,12 +1,13 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/term"
 )
 
 // Default key paths to check, in order of preference
@@ -18,7 +19,9 @@ var defaultKeyPaths = []string{
 
 // PublicKeyAuthenticator provides SSH authentication via private key files.
 type PublicKeyAuthenticator struct {
-	KeyPath string // Optional: specific key path. If empty, checks default locations.
+	KeyPath  string           // Optional: specific key path. If empty, checks default locations.
+	Cert     *ssh.Certificate // Optional: certificate for the key, offered before the plain key
+	Prompter Prompter         // Asks for passphrases of encrypted keys. Defaults to the terminal.
 }
 
 func (p *PublicKeyAuthenticator) Name() string {
@@ -47,6 +50,67 @@ func (p *PublicKeyAuthenticator) IsAvailable() bool {
 }
 
 func (p *PublicKeyAuthenticator) GetAuthMethod() (ssh.AuthMethod, error) {
+	if _, _, err := p.readKey(); err != nil {
+		return nil, err
+	}
+	return ssh.PublicKeysCallback(p.Signers), nil
+}
+
+// Signers returns the key as a signer. Encrypted keys ask for their
+// passphrase only once the server accepts the key, so agent keys and
+// other keys can succeed first. With Cert set, the certificate is
+// offered first.
+func (p *PublicKeyAuthenticator) Signers() ([]ssh.Signer, error) {
+	signer, err := p.signer()
+	if err != nil {
+		return nil, err
+	}
+	if p.Cert == nil {
+		return []ssh.Signer{signer}, nil
+	}
+
+	certSigner, err := ssh.NewCertSigner(p.Cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate for %s: %w", p.KeyPath, err)
+	}
+	return []ssh.Signer{certSigner, signer}, nil
+}
+
+// signer parses the private key
+func (p *PublicKeyAuthenticator) signer() (ssh.Signer, error) {
+	keyPath, key, err := p.readKey()
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if err == nil {
+		return signer, nil
+	}
+
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return nil, fmt.Errorf("unable to parse private key %s: %w", keyPath, err)
+	}
+
+	// Older key formats don't store the public key unencrypted; fall
+	// back to the .pub file, or to asking for the passphrase right away
+	pub := missing.PublicKey
+	if pub == nil {
+		pub, _ = readPublicKey(keyPath + ".pub")
+	}
+	if pub == nil {
+		return p.parseEncryptedKey(key, keyPath)
+	}
+
+	return &encryptedSigner{
+		pub:     pub,
+		decrypt: func() (ssh.Signer, error) { return p.parseEncryptedKey(key, keyPath) },
+	}, nil
+}
+
+// readKey finds and reads the private key file
+func (p *PublicKeyAuthenticator) readKey() (string, []byte, error) {
 	keyPath := p.KeyPath
 	if keyPath == "" {
 		// Find first available key
@@ -62,46 +126,98 @@ func (p *PublicKeyAuthenticator) GetAuthMethod() (ssh.AuthMethod, error) {
 	}
 
 	if keyPath == "" {
-		return nil, fmt.Errorf("no private key found")
+		return "", nil, fmt.Errorf("no private key found")
 	}
 
 	key, err := os.ReadFile(keyPath)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read private key %s: %w", keyPath, err)
+		return "", nil, fmt.Errorf("unable to read private key %s: %w", keyPath, err)
 	}
+	return keyPath, key, nil
+}
 
-	signer, err := ssh.ParsePrivateKey(key)
+func (p *PublicKeyAuthenticator) parseEncryptedKey(key []byte, keyPath string) (ssh.Signer, error) {
+	if signer, ok := signers.get(keyPath); ok {
+		return signer, nil
+	}
+
+	passphrase, err := promptOrTerminal(p.Prompter).Secret(fmt.Sprintf("Enter passphrase for key '%s': ", keyPath))
 	if err != nil {
-		// Key might be encrypted - try with passphrase
-		if _, ok := err.(*ssh.PassphraseMissingError); ok {
-			signer, err = p.parseEncryptedKey(key, keyPath)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			return nil, fmt.Errorf("unable to parse private key: %w", err)
-		}
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse encrypted private key: %w", err)
 	}
 
-	return ssh.PublicKeys(signer), nil
+	signers.put(keyPath, signer)
+	return signer, nil
 }
 
-func (p *PublicKeyAuthenticator) parseEncryptedKey(key []byte, keyPath string) (ssh.Signer, error) {
-	fmt.Printf("Enter passphrase for key '%s': ", keyPath)
+// encryptedSigner is an encrypted private key that is decrypted on first
+// use, after the server has accepted its public key
+type encryptedSigner struct {
+	pub     ssh.PublicKey
+	decrypt func() (ssh.Signer, error)
+}
 
-	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
-	fmt.Println() // newline after password input
+func (s *encryptedSigner) PublicKey() ssh.PublicKey {
+	return s.pub
+}
 
+func (s *encryptedSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	signer, err := s.decrypt()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+		return nil, err
 	}
+	return signer.Sign(rand, data)
+}
 
-	signer, err := ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+// SignWithAlgorithm lets RSA keys sign with SHA-2, which servers require
+func (s *encryptedSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	signer, err := s.decrypt()
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse encrypted private key: %w", err)
+		return nil, err
+	}
+	as, ok := signer.(ssh.AlgorithmSigner)
+	if !ok {
+		return nil, fmt.Errorf("key does not support %s signatures", algorithm)
 	}
+	return as.SignWithAlgorithm(rand, data, algorithm)
+}
 
-	return signer, nil
+// publicKeyFor returns the public key of a private key file, from its
+// .pub file or the key itself
+func publicKeyFor(keyPath string) (ssh.PublicKey, error) {
+	keyPath = expandPath(keyPath)
+	if pub, err := readPublicKey(keyPath + ".pub"); err == nil {
+		return pub, nil
+	}
+
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err == nil {
+		return signer.PublicKey(), nil
+	}
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && missing.PublicKey != nil {
+		return missing.PublicKey, nil
+	}
+	return nil, fmt.Errorf("no public key found for %s", keyPath)
+}
+
+// readPublicKey reads a public key file in authorized_keys format
+func readPublicKey(path string) (ssh.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	return pub, err
 }
 
 // expandPath expands ~ to the user's home directory
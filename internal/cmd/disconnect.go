@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/JoshElias/gurren/internal/daemon"
 	"github.com/spf13/cobra"
@@ -11,17 +12,28 @@ import (
 var disconnectCmd = &cobra.Command{
 	Use:   "disconnect [tunnel-name]",
 	Short: "Disconnect a running tunnel",
-	Long:  `Stops a running tunnel managed by the service.`,
-	Args:  cobra.ExactArgs(1),
-	Run:   runDisconnect,
+	Long: `Stops a running tunnel managed by the service.
+
+With --group and/or --tag, every running tunnel that matches is stopped.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runDisconnect,
 }
 
 func init() {
+	disconnectCmd.Flags().String("group", "", "Disconnect every tunnel in this group")
+	disconnectCmd.Flags().String("tag", "", "Disconnect every tunnel with this tag")
 	rootCmd.AddCommand(disconnectCmd)
 }
 
 func runDisconnect(cmd *cobra.Command, args []string) {
-	name := args[0]
+	group, _ := cmd.Flags().GetString("group")
+	tag, _ := cmd.Flags().GetString("tag")
+	selector := group != "" || tag != ""
+
+	if selector == (len(args) > 0) {
+		fmt.Fprintf(os.Stderr, "Error: give either a tunnel name or --group/--tag\n")
+		os.Exit(1)
+	}
 
 	client, err := daemon.Connect()
 	if err != nil {
@@ -30,6 +42,21 @@ func runDisconnect(cmd *cobra.Command, args []string) {
 	}
 	defer client.Close()
 
+	if selector {
+		result, err := client.TunnelStopGroup(group, tag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(result.Stopped) == 0 {
+			fmt.Println("No matching tunnels were running")
+			return
+		}
+		fmt.Printf("Disconnected %s\n", strings.Join(result.Stopped, ", "))
+		return
+	}
+
+	name := args[0]
 	if err := client.TunnelStop(name); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/JoshElias/gurren/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	followLogs bool
+	logLines   int
+	logsJSON   bool
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [tunnel-name]",
+	Short: "Show tunnel logs",
+	Long: `Shows the recent log of a tunnel, or of every tunnel: connection
+attempts, auth methods tried, and failures accepting, dialing or copying
+forwarded connections.
+
+With -f, keeps printing new entries as they happen.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runLogs,
+}
+
+func init() {
+	logsCmd.Flags().BoolVarP(&followLogs, "follow", "f", false, "Keep printing new entries")
+	logsCmd.Flags().IntVarP(&logLines, "lines", "n", 50, "Number of recent entries to show (0 for all kept)")
+	logsCmd.Flags().BoolVar(&logsJSON, "json", false, "Print entries as JSON, one per line")
+	rootCmd.AddCommand(logsCmd)
+}
+
+func runLogs(cmd *cobra.Command, args []string) {
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	client, err := daemon.Connect()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: service not running. Start with 'gurren service start'\n")
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	// Subscribe before fetching so nothing is missed in between;
+	// entries seen in both are skipped below
+	if followLogs {
+		if err := client.SubscribeLogs(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	result, err := client.TunnelLogs(name, logLines)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var last time.Time
+	for _, e := range result.Entries {
+		printLogEntry(e, name == "")
+		last = e.Time
+	}
+
+	if !followLogs {
+		if len(result.Entries) == 0 && !logsJSON {
+			fmt.Println("No log entries")
+		}
+		return
+	}
+
+	for notif := range client.Notifications() {
+		if notif.Method != daemon.MethodTunnelLog {
+			continue
+		}
+		var e daemon.LogEntry
+		if err := json.Unmarshal(notif.Params, &e); err != nil || !e.Time.After(last) {
+			continue
+		}
+		printLogEntry(e, name == "")
+	}
+
+	fmt.Fprintln(os.Stderr, "Service stopped")
+	os.Exit(1)
+}
+
+// printLogEntry prints a log entry on one line, with the tunnel name
+// when showing several tunnels
+func printLogEntry(e daemon.LogEntry, withName bool) {
+	if logsJSON {
+		data, _ := json.Marshal(e)
+		fmt.Println(string(data))
+		return
+	}
+
+	prefix := e.Time.Local().Format(time.DateTime)
+	if withName {
+		prefix += " " + e.Name
+	}
+	fmt.Printf("%s %-5s %s: %s\n", prefix, strings.ToUpper(string(e.Level)), e.Event, e.Message)
+}
@@ -5,12 +5,17 @@ import (
 	"fmt"
 	"os"
 	"text/tabwriter"
+	"time"
 
 	"github.com/JoshElias/gurren/internal/daemon"
+	"github.com/JoshElias/gurren/internal/tunnel"
 	"github.com/spf13/cobra"
 )
 
-var jsonOutput bool
+var (
+	jsonOutput bool
+	showStats  bool
+)
 
 var lsCmd = &cobra.Command{
 	Use:   "ls",
@@ -21,6 +26,7 @@ var lsCmd = &cobra.Command{
 
 func init() {
 	lsCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+	lsCmd.Flags().BoolVarP(&showStats, "stats", "s", false, "Show traffic and connection columns")
 	rootCmd.AddCommand(lsCmd)
 }
 
@@ -31,6 +37,7 @@ func runLs(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 	defer client.Close()
+	warnConfigMismatch(client)
 
 	result, err := client.TunnelList()
 	if err != nil {
@@ -54,15 +61,86 @@ func runLs(cmd *cobra.Command, args []string) {
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "NAME\tSTATUS\tLOCAL\tREMOTE")
+	header := "NAME\tSTATUS\tUPTIME\tLOCAL\tREMOTE"
+	if showStats {
+		header += "\tIN\tOUT\tCONNS\tFAILED\tLAST ACTIVE"
+	}
+	fmt.Fprintln(w, header)
 
 	for _, t := range result.Tunnels {
 		status := string(t.Status)
-		if t.Status == "error" && t.Error != "" {
-			status = fmt.Sprintf("error: %s", t.Error)
+		if t.Status.IsError() && t.Error != "" {
+			status = fmt.Sprintf("%s: %s", t.Status, t.Error)
+		} else if t.Status == tunnel.StateReconnecting {
+			status = fmt.Sprintf("%s (attempt %d, retry in %s)", t.Status, t.Attempt, formatRetry(t.NextRetry))
+		}
+		if t.RestartPending {
+			status += " (restart pending)"
+		}
+		if len(t.Forwards) <= 1 {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s%s\n", t.Name, status, uptime(t.ConnectedAt), t.Config.Local, remoteColumn(t.Config.Type, t.Config.Remote, t.ListenAddr), statsColumns(t.Stats))
+			continue
+		}
+
+		// One row per forward under the tunnel
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d forwards\t%s\n", t.Name, status, uptime(t.ConnectedAt), len(t.Forwards), statsColumns(t.Stats))
+		for i, f := range t.Forwards {
+			branch := "├─"
+			if i == len(t.Forwards)-1 {
+				branch = "└─"
+			}
+			fmt.Fprintf(w, "  %s %s\t%s\t\t%s\t%s\n", branch, f.Name, f.Error, f.Local, remoteColumn(f.Type, f.Remote, f.ListenAddr))
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Name, status, t.Config.Local, t.Config.Remote)
 	}
 
 	w.Flush()
 }
+
+// formatRetry returns how long until a reconnect attempt, rounded to seconds
+func formatRetry(at time.Time) string {
+	d := time.Until(at).Round(time.Second)
+	if d < 0 {
+		d = 0
+	}
+	return d.String()
+}
+
+// uptime returns how long a tunnel has been connected, or "-" if it isn't
+func uptime(connectedAt time.Time) string {
+	if connectedAt.IsZero() {
+		return "-"
+	}
+	return tunnel.FormatUptime(time.Since(connectedAt))
+}
+
+// statsColumns returns the --stats columns for a tunnel, with a leading
+// tab, or "" without --stats
+func statsColumns(s daemon.TunnelStats) string {
+	if !showStats {
+		return ""
+	}
+
+	lastActive := "-"
+	if !s.LastActivity.IsZero() {
+		lastActive = time.Since(s.LastActivity).Round(time.Second).String() + " ago"
+	}
+	return fmt.Sprintf("\t%s\t%s\t%d/%d\t%d\t%s",
+		tunnel.FormatBytes(s.BytesIn), tunnel.FormatBytes(s.BytesOut),
+		s.ActiveConns, s.TotalConns, s.FailedDials, lastActive)
+}
+
+// remoteColumn returns the remote side of a forward, for display
+func remoteColumn(kind, remote, listenAddr string) string {
+	switch tunnel.Kind(kind) {
+	case tunnel.KindSocks:
+		return "(socks5 proxy)"
+	case tunnel.KindRemote:
+		// Show the server-assigned address once listening
+		if listenAddr != "" {
+			remote = listenAddr
+		}
+		return remote + " (remote listener)"
+	default:
+		return remote
+	}
+}
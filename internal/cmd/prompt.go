@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/JoshElias/gurren/internal/auth"
+	"github.com/JoshElias/gurren/internal/daemon"
+)
+
+// stdinReader is shared so buffered input isn't lost between prompts
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// terminal answers secret and challenge prompts through stdinReader
+var terminal = auth.TerminalPrompter{In: stdinReader}
+
+// handlePrompt answers a prompt notification from the daemon on the terminal.
+// Returns false if the notification is not a prompt.
+func handlePrompt(client *daemon.Client, notif daemon.Notification) bool {
+	switch notif.Method {
+	case daemon.MethodHostKeyPrompt:
+		var params daemon.HostKeyPromptParams
+		if err := json.Unmarshal(notif.Params, &params); err != nil {
+			return true
+		}
+
+		accept := confirmHostKey(params)
+		if err := client.AnswerHostKey(params.ID, accept); err != nil {
+			log.Printf("Warning: failed to answer host key prompt: %v", err)
+		}
+		return true
+
+	case daemon.MethodSecretPrompt:
+		var params daemon.SecretPromptParams
+		if err := json.Unmarshal(notif.Params, &params); err != nil {
+			return true
+		}
+
+		// A failed read (e.g. Ctrl+D) cancels the prompt
+		secret, readErr := terminal.Secret(params.Prompt)
+		if err := client.AnswerSecret(params.ID, secret, readErr != nil); err != nil {
+			log.Printf("Warning: failed to answer prompt: %v", err)
+		}
+		return true
+
+	case daemon.MethodChallengePrompt:
+		var params daemon.ChallengePromptParams
+		if err := json.Unmarshal(notif.Params, &params); err != nil {
+			return true
+		}
+
+		questions := make([]string, len(params.Questions))
+		echos := make([]bool, len(params.Questions))
+		for i, q := range params.Questions {
+			questions[i], echos[i] = q.Prompt, q.Echo
+		}
+
+		answers, readErr := terminal.Challenge(params.Title, params.Instruction, questions, echos)
+		if err := client.AnswerChallenge(params.ID, answers, readErr != nil); err != nil {
+			log.Printf("Warning: failed to answer prompt: %v", err)
+		}
+		return true
+
+	case daemon.MethodPromptResolved:
+		return true
+	}
+
+	return false
+}
+
+// confirmHostKey asks the user whether to trust an unknown host key,
+// in the same words OpenSSH uses
+func confirmHostKey(params daemon.HostKeyPromptParams) bool {
+	keyType := strings.ToUpper(strings.TrimPrefix(params.KeyType, "ssh-"))
+
+	fmt.Printf("The authenticity of host '%s' can't be established.\n", params.Host)
+	fmt.Printf("%s key fingerprint is %s.\n", keyType, params.Fingerprint)
+
+	for {
+		fmt.Print("Are you sure you want to continue connecting (yes/no)? ")
+
+		line, err := stdinReader.ReadString('\n')
+		if err != nil {
+			fmt.Println()
+			return false
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "yes", "y":
+			return true
+		case "no", "n":
+			return false
+		}
+		fmt.Println("Please type 'yes' or 'no'.")
+	}
+}
@@ -8,11 +8,13 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/JoshElias/gurren/internal/daemon"
 	"github.com/JoshElias/gurren/internal/tui"
+	"github.com/JoshElias/gurren/internal/tunnel"
 	"github.com/spf13/cobra"
 )
 
@@ -34,19 +36,25 @@ var connectCmd = &cobra.Command{
 	Long: `Connect establishes an SSH tunnel to a remote service.
 
 If a tunnel name is provided, it uses the configuration from the config file.
+With --group and/or --tag, every configured tunnel that matches is started.
 Otherwise, you can specify the connection details via flags.`,
 	Args: cobra.MaximumNArgs(1),
 	Run:  runConnect,
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: ~/.config/gurren/config.toml)")
-	rootCmd.PersistentFlags().StringVarP(&authMethod, "auth", "a", "", "auth method: auto, agent, publickey, password (default: auto)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: $GURREN_CONFIG or ~/.config/gurren/config.toml)")
+	rootCmd.PersistentFlags().StringVarP(&authMethod, "auth", "a", "", "auth method for ad-hoc tunnels: auto, agent, publickey, keyboard-interactive, password (default: from config)")
 
 	// Connect command flags
 	connectCmd.Flags().String("host", "", "SSH host (user@host:port or host from ~/.ssh/config)")
 	connectCmd.Flags().String("remote", "", "Remote address (host:port)")
 	connectCmd.Flags().String("local", "", "Local bind address (host:port)")
+	connectCmd.Flags().Bool("socks", false, "Run a SOCKS5 proxy on --local instead of forwarding to --remote (like ssh -D)")
+	connectCmd.Flags().StringSliceP("jump", "J", nil, "Jump hosts to connect through, comma separated (like ssh -J)")
+	connectCmd.Flags().Bool("reverse", false, "Listen on --remote on the SSH host and forward to --local (like ssh -R)")
+	connectCmd.Flags().String("group", "", "Connect every tunnel in this group")
+	connectCmd.Flags().String("tag", "", "Connect every tunnel with this tag")
 
 	rootCmd.AddCommand(connectCmd)
 }
@@ -70,6 +78,23 @@ func runConnect(cmd *cobra.Command, args []string) {
 		log.Fatalf("Failed to connect to service: %v", err)
 	}
 	defer client.Close()
+	warnConfigMismatch(client)
+
+	// Subscribe before starting so prompts raised while connecting reach us
+	if err := client.Subscribe(false); err != nil {
+		log.Printf("Warning: couldn't subscribe to notifications: %v", err)
+	}
+
+	// Several tunnels selected by group or tag
+	group, _ := cmd.Flags().GetString("group")
+	tag, _ := cmd.Flags().GetString("tag")
+	if group != "" || tag != "" {
+		if len(args) > 0 {
+			log.Fatal("A tunnel name can't be combined with --group or --tag")
+		}
+		runConnectGroup(client, group, tag)
+		return
+	}
 
 	var tunnelName string
 
@@ -81,12 +106,27 @@ func runConnect(cmd *cobra.Command, args []string) {
 		host, _ := cmd.Flags().GetString("host")
 		remote, _ := cmd.Flags().GetString("remote")
 		local, _ := cmd.Flags().GetString("local")
+		socks, _ := cmd.Flags().GetBool("socks")
+		reverse, _ := cmd.Flags().GetBool("reverse")
+		jump, _ := cmd.Flags().GetStringSlice("jump")
 
-		if host == "" || remote == "" || local == "" {
+		params := daemon.TunnelRegisterParams{Host: host, Remote: remote, Local: local, Jump: jump, Auth: authMethod}
+		if socks && reverse {
+			log.Fatal("--socks and --reverse can't be used together")
+		}
+		if reverse {
+			params.Type = string(tunnel.KindRemote)
+		}
+		if socks {
+			params.Type = string(tunnel.KindSocks)
+			if host == "" || local == "" {
+				log.Fatal("With --socks, --host and --local are required")
+			}
+		} else if host == "" || remote == "" || local == "" {
 			log.Fatal("When not using a named tunnel, --host, --remote, and --local are required")
 		}
 
-		result, err := client.TunnelRegister(host, remote, local)
+		result, err := client.TunnelRegister(params)
 		if err != nil {
 			log.Fatalf("Failed to register tunnel: %v", err)
 		}
@@ -94,71 +134,249 @@ func runConnect(cmd *cobra.Command, args []string) {
 		fmt.Printf("Registered ad-hoc tunnel: %s\n", tunnelName)
 	}
 
-	// Start the tunnel
-	_, err = client.TunnelStart(tunnelName)
-	if err != nil {
-		log.Fatalf("Failed to start tunnel: %v", err)
+	var connectedOnce sync.Once
+	printConnected := func() {
+		connectedOnce.Do(func() { printTunnelConnected(client, tunnelName) })
 	}
 
-	// Get tunnel details for display
-	tunnelList, err := client.TunnelList()
-	if err != nil {
-		log.Printf("Warning: couldn't fetch tunnel details: %v", err)
-	} else {
-		for _, t := range tunnelList.Tunnels {
-			if t.Name == tunnelName {
-				fmt.Printf("Tunnel %q connected.\n", tunnelName)
-				fmt.Printf("  %s -> %s (via %s)\n", t.Config.Local, t.Config.Remote, t.Config.Host)
-				break
+	// Listen for notifications in background:
+	// answer prompts, and watch for the tunnel connecting or stopping
+	// (stopped from TUI or another CLI)
+	var disconnectErr string
+	doneCh := make(chan struct{})
+	go func() {
+		reconnecting := false
+		for notif := range client.Notifications() {
+			if handlePrompt(client, notif) {
+				continue
+			}
+			if notif.Method != daemon.MethodStatusChanged {
+				continue
+			}
+
+			var params daemon.StatusChangedParams
+			if err := json.Unmarshal(notif.Params, &params); err != nil || params.Name != tunnelName {
+				continue
+			}
+			switch {
+			case params.Status == tunnel.StateConnected:
+				if reconnecting {
+					fmt.Printf("Tunnel %q reconnected.\n", tunnelName)
+					reconnecting = false
+				}
+				printConnected()
+			case params.Status == tunnel.StateReconnecting:
+				reconnecting = true
+				fmt.Printf("Connection lost (%s), reconnecting in %s (attempt %d)...\n",
+					params.Error, formatRetry(params.NextRetry), params.Attempt)
+			case !params.Status.IsActive():
+				disconnectErr = params.Error
+				close(doneCh)
+				return
 			}
 		}
-	}
+	}()
 
-	fmt.Println("Press Ctrl+C to disconnect.")
+	// Catch Ctrl+C from here on, so the tunnel is stopped even if it is
+	// pressed while the start request is still waiting
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
-	// Subscribe to notifications to detect if tunnel is stopped elsewhere
-	if err := client.Subscribe(); err != nil {
-		log.Printf("Warning: couldn't subscribe to notifications: %v", err)
+	// Start the tunnel - returns once it is connected, has failed or timed
+	// out. The request runs in the background so Ctrl+C can stop the
+	// tunnel without waiting for it.
+	type startResult struct {
+		result *daemon.TunnelStatusResult
+		err    error
+	}
+	startCh := make(chan startResult, 1)
+	go func() {
+		result, err := client.TunnelStart(tunnelName)
+		startCh <- startResult{result, err}
+	}()
+
+	var result *daemon.TunnelStatusResult
+	select {
+	case <-sigCh:
+		fmt.Println("\nDisconnecting...")
+		if err := client.TunnelStop(tunnelName); err != nil {
+			// The start hadn't reached the service yet; stop the tunnel
+			// once it has, unless it didn't come up
+			if started := <-startCh; started.err != nil || !started.result.Status.IsActive() {
+				err = nil
+			} else {
+				err = client.TunnelStop(tunnelName)
+			}
+			if err != nil {
+				log.Printf("Warning: failed to stop tunnel: %v", err)
+			}
+		}
+		fmt.Printf("Tunnel %q disconnected.\n", tunnelName)
+		return
+	case started := <-startCh:
+		if started.err != nil {
+			log.Fatalf("Failed to start tunnel: %v", started.err)
+		}
+		result = started.result
+	}
+	switch {
+	case result.Status == tunnel.StateConnected:
+		printConnected()
+	case result.Status.IsError():
+		if result.ErrorPhase != "" {
+			fmt.Fprintf(os.Stderr, "Tunnel %q failed (%s): %s\n", tunnelName, result.ErrorPhase, result.Error)
+		} else {
+			fmt.Fprintf(os.Stderr, "Tunnel %q failed: %s\n", tunnelName, result.Error)
+		}
+		os.Exit(1)
+	default:
+		// Still connecting after the service's start timeout
+		fmt.Printf("Connecting tunnel %q...\n", tunnelName)
 	}
 
+	fmt.Println("Press Ctrl+C to disconnect.")
+
 	// Wait for either:
 	// 1. Interrupt signal (user pressed Ctrl+C)
-	// 2. Tunnel disconnected notification (stopped from TUI or another CLI)
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-
-	disconnectedByRemote := false
+	// 2. Tunnel disconnected notification
+	select {
+	case <-sigCh:
+		fmt.Println("\nDisconnecting...")
+		if err := client.TunnelStop(tunnelName); err != nil {
+			log.Printf("Warning: failed to stop tunnel: %v", err)
+		}
+		fmt.Printf("Tunnel %q disconnected.\n", tunnelName)
+	case <-doneCh:
+		if disconnectErr != "" {
+			fmt.Fprintf(os.Stderr, "\nTunnel %q failed: %s\n", tunnelName, disconnectErr)
+			os.Exit(1)
+		}
+		fmt.Println("\nTunnel disconnected.")
+	}
+}
 
-	// Listen for notifications in background
+// runConnectGroup starts every tunnel in group with tag, then waits until
+// Ctrl+C (which stops them) or until all of them have stopped
+func runConnectGroup(client *daemon.Client, group, tag string) {
+	// Tunnels still up, once the start request has returned
+	var mu sync.Mutex
+	var active map[string]bool
 	doneCh := make(chan struct{})
+
 	go func() {
 		for notif := range client.Notifications() {
-			if notif.Method == daemon.MethodStatusChanged {
-				var params daemon.StatusChangedParams
-				if err := json.Unmarshal(notif.Params, &params); err == nil {
-					if params.Name == tunnelName && !params.Status.IsActive() {
-						disconnectedByRemote = true
-						close(doneCh)
-						return
-					}
+			if handlePrompt(client, notif) {
+				continue
+			}
+			if notif.Method != daemon.MethodStatusChanged {
+				continue
+			}
+
+			var params daemon.StatusChangedParams
+			if err := json.Unmarshal(notif.Params, &params); err != nil {
+				continue
+			}
+
+			mu.Lock()
+			if !active[params.Name] {
+				mu.Unlock()
+				continue
+			}
+			switch {
+			case params.Status == tunnel.StateConnected:
+				fmt.Printf("Tunnel %q connected.\n", params.Name)
+			case params.Status == tunnel.StateReconnecting:
+				fmt.Printf("Tunnel %q lost its connection (%s), reconnecting in %s (attempt %d)...\n",
+					params.Name, params.Error, formatRetry(params.NextRetry), params.Attempt)
+			case !params.Status.IsActive():
+				if params.Error != "" {
+					fmt.Fprintf(os.Stderr, "Tunnel %q failed: %s\n", params.Name, params.Error)
+				} else {
+					fmt.Printf("Tunnel %q disconnected.\n", params.Name)
+				}
+				delete(active, params.Name)
+				if len(active) == 0 {
+					close(doneCh)
 				}
 			}
+			mu.Unlock()
 		}
 	}()
 
-	// Wait for signal or remote disconnect
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	// Returns once every tunnel is connected, has failed or timed out
+	result, err := client.TunnelStartGroup(group, tag)
+	if err != nil {
+		log.Fatalf("Failed to start tunnels: %v", err)
+	}
+
+	mu.Lock()
+	active = make(map[string]bool)
+	for _, t := range result.Tunnels {
+		switch {
+		case t.Status == tunnel.StateConnected:
+			printTunnelConnected(client, t.Name)
+		case t.Status.IsError():
+			if t.ErrorPhase != "" {
+				fmt.Fprintf(os.Stderr, "Tunnel %q failed (%s): %s\n", t.Name, t.ErrorPhase, t.Error)
+			} else {
+				fmt.Fprintf(os.Stderr, "Tunnel %q failed: %s\n", t.Name, t.Error)
+			}
+		default:
+			fmt.Printf("Connecting tunnel %q...\n", t.Name)
+		}
+		if t.Status.IsActive() {
+			active[t.Name] = true
+		}
+	}
+	up := len(active)
+	mu.Unlock()
+
+	if up == 0 {
+		os.Exit(1)
+	}
+
+	fmt.Println("Press Ctrl+C to disconnect.")
+
 	select {
 	case <-sigCh:
 		fmt.Println("\nDisconnecting...")
-		if err := client.TunnelStop(tunnelName); err != nil {
-			log.Printf("Warning: failed to stop tunnel: %v", err)
+		stopped, err := client.TunnelStopGroup(group, tag)
+		if err != nil {
+			log.Printf("Warning: failed to stop tunnels: %v", err)
+			return
 		}
+		fmt.Printf("Disconnected %d tunnel(s).\n", len(stopped.Stopped))
 	case <-doneCh:
-		fmt.Println("\nTunnel disconnected.")
+		fmt.Println("\nAll tunnels disconnected.")
 	}
+}
 
-	if !disconnectedByRemote {
-		fmt.Printf("Tunnel %q disconnected.\n", tunnelName)
+// printTunnelConnected prints the endpoints of a connected tunnel
+func printTunnelConnected(client *daemon.Client, name string) {
+	tunnelList, err := client.TunnelList()
+	if err != nil {
+		log.Printf("Warning: couldn't fetch tunnel details: %v", err)
+		return
+	}
+
+	for _, t := range tunnelList.Tunnels {
+		if t.Name == name {
+			fmt.Printf("Tunnel %q connected.\n", name)
+			for _, f := range t.Forwards {
+				switch tunnel.Kind(f.Type) {
+				case tunnel.KindSocks:
+					fmt.Printf("  SOCKS5 proxy on %s (via %s)\n", f.ListenAddr, t.Config.Host)
+				case tunnel.KindRemote:
+					fmt.Printf("  %s on %s -> %s\n", f.ListenAddr, t.Config.Host, f.Local)
+				default:
+					fmt.Printf("  %s -> %s (via %s)\n", f.ListenAddr, f.Remote, t.Config.Host)
+				}
+			}
+			return
+		}
 	}
 }
 
@@ -183,6 +401,7 @@ func runRoot(cmd *cobra.Command, args []string) {
 		log.Fatalf("Failed to connect to service: %v", err)
 	}
 	defer client.Close()
+	warnConfigMismatch(client)
 
 	// Run TUI
 	if err := tui.Run(client); err != nil {
@@ -199,7 +418,7 @@ func startServiceBackground() error {
 	}
 
 	// Start service in background
-	cmd := exec.Command(exePath, "service", "start")
+	cmd := exec.Command(exePath, append([]string{"service", "start"}, configArgs()...)...)
 	cmd.Stdout = nil
 	cmd.Stderr = nil
 	cmd.Stdin = nil
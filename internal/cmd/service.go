@@ -20,7 +20,10 @@ import (
 //go:embed gurren.service
 var serviceFileTemplate string
 
-var serviceForeground bool
+var (
+	serviceForeground bool
+	serviceNoRestore  bool
+)
 
 var serviceCmd = &cobra.Command{
 	Use:   "service",
@@ -31,8 +34,11 @@ var serviceCmd = &cobra.Command{
 var serviceStartCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start the service",
-	Long:  `Starts the service in the background to manage SSH tunnels.`,
-	Run:   runServiceStart,
+	Long: `Starts the service in the background to manage SSH tunnels.
+
+Tunnels marked autostart, and those that were up when the service last
+stopped, are started too unless --no-restore is given.`,
+	Run: runServiceStart,
 }
 
 var serviceStopCmd = &cobra.Command{
@@ -49,6 +55,27 @@ var serviceStatusCmd = &cobra.Command{
 	Run:   runServiceStatus,
 }
 
+var serviceReloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Reload the config file",
+	Long: `Reloads the config file without stopping the service. New tunnels are
+added and deleted ones removed. Changed tunnels that are running keep
+going and pick up the change when they are next restarted.
+
+The service also reloads automatically when the config file changes.`,
+	Run: runServiceReload,
+}
+
+var serviceLogLevelCmd = &cobra.Command{
+	Use:   "log-level [debug|info|warn|error]",
+	Short: "Show or change the service log level",
+	Long: `Shows the service log level, or changes it without restarting the
+service or its tunnels. The change lasts until the service restarts;
+set log_level in the [daemon] config section to keep it.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runServiceLogLevel,
+}
+
 var serviceInstallCmd = &cobra.Command{
 	Use:   "install",
 	Short: "Install systemd user service",
@@ -79,9 +106,12 @@ var serviceDisableCmd = &cobra.Command{
 
 func init() {
 	serviceStartCmd.Flags().BoolVar(&serviceForeground, "foreground", false, "Run service in foreground (don't detach)")
+	serviceStartCmd.Flags().BoolVar(&serviceNoRestore, "no-restore", false, "Don't start autostart tunnels or restore the previous session")
 	serviceCmd.AddCommand(serviceStartCmd)
 	serviceCmd.AddCommand(serviceStopCmd)
 	serviceCmd.AddCommand(serviceStatusCmd)
+	serviceCmd.AddCommand(serviceReloadCmd)
+	serviceCmd.AddCommand(serviceLogLevelCmd)
 	serviceCmd.AddCommand(serviceInstallCmd)
 	serviceCmd.AddCommand(serviceUninstallCmd)
 	serviceCmd.AddCommand(serviceEnableCmd)
@@ -106,15 +136,24 @@ func runServiceStart(cmd *cobra.Command, args []string) {
 	}
 
 	// Foreground mode - run service in this process
-	cfg, err := config.Load()
+	cfg, err := config.Load(cfgFile)
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
 
+	logFile, err := daemon.SetupLogging(cfg.Daemon)
+	if err != nil {
+		log.Fatalf("Error setting up logging: %v", err)
+	}
+	defer func() { _ = logFile.Close() }()
+
 	d := daemon.New(cfg)
 	if err := d.Start(); err != nil {
 		log.Fatalf("Error starting service: %v", err)
 	}
+	if !serviceNoRestore {
+		d.Restore()
+	}
 
 	// Wait for interrupt signal
 	sigCh := make(chan os.Signal, 1)
@@ -132,7 +171,12 @@ func startServiceInBackground() error {
 		return fmt.Errorf("failed to get executable path: %w", err)
 	}
 
-	cmd := exec.Command(exePath, "service", "start", "--foreground")
+	args := append([]string{"service", "start", "--foreground"}, configArgs()...)
+	if serviceNoRestore {
+		args = append(args, "--no-restore")
+	}
+
+	cmd := exec.Command(exePath, args...)
 	cmd.Stdout = nil
 	cmd.Stderr = nil
 	cmd.Stdin = nil
@@ -186,6 +230,102 @@ func runServiceStatus(cmd *cobra.Command, args []string) {
 	}
 
 	fmt.Printf("Service is running (version %s)\n", result.Version)
+	fmt.Printf("Config file: %s\n", describeConfigFile(result.ConfigFile))
+}
+
+func runServiceReload(cmd *cobra.Command, args []string) {
+	client, err := daemon.Connect()
+	if err != nil {
+		fmt.Println("Service is not running")
+		os.Exit(1)
+	}
+	defer func() { _ = client.Close() }()
+
+	result, err := client.Reload()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Reloaded %s\n", describeConfigFile(result.ConfigFile))
+	printReloaded("Added", result.Added)
+	printReloaded("Removed", result.Removed)
+	printReloaded("Updated", result.Updated)
+	printReloaded("Restart pending", result.RestartPending)
+}
+
+func runServiceLogLevel(cmd *cobra.Command, args []string) {
+	var level string
+	if len(args) > 0 {
+		level = args[0]
+	}
+
+	client, err := daemon.Connect()
+	if err != nil {
+		fmt.Println("Service is not running")
+		os.Exit(1)
+	}
+	defer func() { _ = client.Close() }()
+
+	result, err := client.SetLogLevel(level)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if level == "" || result.Level == result.Previous {
+		fmt.Printf("Log level: %s\n", result.Level)
+		return
+	}
+	fmt.Printf("Log level changed from %s to %s\n", result.Previous, result.Level)
+}
+
+// printReloaded prints the tunnels affected by a reload, if any
+func printReloaded(label string, names []string) {
+	if len(names) > 0 {
+		fmt.Printf("  %s: %s\n", label, strings.Join(names, ", "))
+	}
+}
+
+// configArgs returns the flags passing the --config path on to a spawned
+// service. The path is made absolute since the service may not share our
+// working directory.
+func configArgs() []string {
+	if cfgFile == "" {
+		return nil
+	}
+	path, err := filepath.Abs(cfgFile)
+	if err != nil {
+		path = cfgFile
+	}
+	return []string{"--config", path}
+}
+
+// warnConfigMismatch warns if the running service loaded a different
+// config file than this command would, e.g. when it was started with
+// another --config
+func warnConfigMismatch(client *daemon.Client) {
+	result, err := client.Ping()
+	if err != nil {
+		return
+	}
+	path, err := config.FindFile(cfgFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	if path != result.ConfigFile {
+		fmt.Fprintf(os.Stderr, "Warning: service is using config %s, not %s. Restart it with 'gurren service stop' to switch.\n",
+			describeConfigFile(result.ConfigFile), describeConfigFile(path))
+	}
+}
+
+// describeConfigFile returns a config path for display
+func describeConfigFile(path string) string {
+	if path == "" {
+		return "(none, using defaults)"
+	}
+	return path
 }
 
 // systemd helpers
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -13,21 +15,152 @@ import (
 // Config holds the application configuration.
 type Config struct {
 	Auth    AuthConfig     `mapstructure:"auth"`
+	Daemon  DaemonConfig   `mapstructure:"daemon"`
+	Metrics MetricsConfig  `mapstructure:"metrics"`
 	Tunnels []TunnelConfig `mapstructure:"tunnels"`
+
+	File string `mapstructure:"-"` // Path of the loaded config file, "" if none was found
+}
+
+// DaemonConfig holds settings for the background service.
+type DaemonConfig struct {
+	StartTimeout     time.Duration `mapstructure:"start_timeout"`     // How long tunnel.start waits for a tunnel to come up
+	RestoreEphemeral bool          `mapstructure:"restore_ephemeral"` // Also restore ad-hoc tunnels that were up when the service stopped
+	LogLevel         string        `mapstructure:"log_level"`         // "debug", "info", "warn" or "error"
+	LogFormat        string        `mapstructure:"log_format"`        // "text" or "json"
+	LogMaxSize       int           `mapstructure:"log_max_size"`      // Megabytes the log file grows to before it's rotated
+	LogMaxFiles      int           `mapstructure:"log_max_files"`     // Rotated log files kept besides the current one
+}
+
+// MetricsConfig holds settings for the Prometheus metrics endpoint.
+type MetricsConfig struct {
+	Listen string `mapstructure:"listen"` // Address to serve /metrics on, e.g. "127.0.0.1:9788"; disabled if empty
 }
 
-// AuthConfig holds authentication settings.
+// AuthConfig holds authentication settings. Tunnels can override all
+// but CacheTTL in their own auth block.
 type AuthConfig struct {
-	Method  string `mapstructure:"method"`   // "auto", "agent", "publickey", "password"
-	KeyPath string `mapstructure:"key_path"` // Optional: specific key path for publickey auth
+	Method         string        `mapstructure:"method"`          // "auto", "agent", "publickey", "keyboard-interactive", "password"
+	Methods        []string      `mapstructure:"methods"`         // Several methods to try in order, instead of Method
+	KeyPath        string        `mapstructure:"key_path"`        // Optional: specific key path for publickey auth
+	KeyPaths       []string      `mapstructure:"key_paths"`       // More keys to try, after KeyPath
+	IdentitiesOnly bool          `mapstructure:"identities_only"` // Only offer the configured keys, even from the agent
+	AgentSocket    string        `mapstructure:"agent_socket"`    // SSH agent socket (default $SSH_AUTH_SOCK)
+	CacheTTL       time.Duration `mapstructure:"cache_ttl"`       // How long decrypted keys stay in memory ("0" disables)
+}
+
+// MethodList returns the methods to try: Methods if set, otherwise Method
+func (a AuthConfig) MethodList() []string {
+	if len(a.Methods) > 0 {
+		return a.Methods
+	}
+	if a.Method != "" {
+		return []string{a.Method}
+	}
+	return nil
+}
+
+// KeyPathList returns KeyPath followed by KeyPaths
+func (a AuthConfig) KeyPathList() []string {
+	var keyPaths []string
+	if a.KeyPath != "" {
+		keyPaths = append(keyPaths, a.KeyPath)
+	}
+	return append(keyPaths, a.KeyPaths...)
 }
 
 // TunnelConfig defines a tunnel to a remote endpoint via an SSH host.
 type TunnelConfig struct {
 	Name   string `mapstructure:"name"`   // Friendly name for the tunnel (optional, derived from Host if omitted)
+	Type   string `mapstructure:"type"`   // "local" (default), "socks" or "remote"
 	Host   string `mapstructure:"host"`   // SSH host (from ~/.ssh/config or hostname)
+	Remote string `mapstructure:"remote"` // Remote address (host:port); for remote tunnels, the bind address on the SSH host; unused for socks
+	Local  string `mapstructure:"local"`  // Local bind address (host:port); for remote tunnels, the local target
+
+	Group string   `mapstructure:"group"` // Group the tunnel belongs to, e.g. an environment like "staging"
+	Tags  []string `mapstructure:"tags"`  // Labels for selecting tunnels, e.g. "db"
+
+	Autostart bool `mapstructure:"autostart"` // Start the tunnel when the service starts
+
+	Auth AuthConfig `mapstructure:"auth"` // Overrides the global auth settings that are set
+
+	Forwards []ForwardConfig `mapstructure:"forwards"` // Several forwards through the same host, instead of Type/Remote/Local
+
+	Jump []string `mapstructure:"jump"` // Jump hosts to connect through, in order (overrides ProxyJump)
+
+	Socks SocksConfig `mapstructure:"socks"` // SOCKS proxy settings (type = "socks")
+
+	HostKeyPolicy string `mapstructure:"host_key_policy"` // "accept-new" (default), "ask", "strict", or "off"
+
+	Reconnect ReconnectConfig `mapstructure:"reconnect"` // Automatic reconnect after the connection drops
+
+	KeepaliveInterval time.Duration `mapstructure:"keepalive_interval"`  // Overrides ServerAliveInterval (negative disables)
+	KeepaliveCountMax int           `mapstructure:"keepalive_count_max"` // Overrides ServerAliveCountMax
+}
+
+// ForwardConfig is one port forward of a tunnel with several.
+// Type, Remote and Local mean the same as on TunnelConfig.
+type ForwardConfig struct {
+	Name   string `mapstructure:"name"`   // Label for status and errors (optional, derived from the addresses)
+	Type   string `mapstructure:"type"`   // "local" (default), "socks" or "remote"
 	Remote string `mapstructure:"remote"` // Remote address (host:port)
-	Local  string `mapstructure:"local"`  // Local bind address (host:port)
+	Local  string `mapstructure:"local"`  // Local address (host:port)
+}
+
+// Clone returns a copy of the config that shares no slices with it
+func (tc TunnelConfig) Clone() TunnelConfig {
+	tc.Tags = slices.Clone(tc.Tags)
+	tc.Auth.Methods = slices.Clone(tc.Auth.Methods)
+	tc.Auth.KeyPaths = slices.Clone(tc.Auth.KeyPaths)
+	tc.Forwards = slices.Clone(tc.Forwards)
+	tc.Jump = slices.Clone(tc.Jump)
+	return tc
+}
+
+// ForwardList returns the tunnel's forwards: its forwards list if set,
+// otherwise a single forward from Type, Remote and Local. Forwards
+// without a name are named after the address they listen on.
+func (tc TunnelConfig) ForwardList() []ForwardConfig {
+	forwards := tc.Forwards
+	if len(forwards) == 0 {
+		forwards = []ForwardConfig{{Type: tc.Type, Remote: tc.Remote, Local: tc.Local}}
+	}
+
+	list := make([]ForwardConfig, len(forwards))
+	for i, f := range forwards {
+		if f.Name == "" {
+			f.Name = f.Local
+			if f.Type == "remote" {
+				f.Name = f.Remote
+			}
+		}
+		list[i] = f
+	}
+	return list
+}
+
+// Matches reports whether the tunnel is in group and has tag.
+// An empty group or tag matches any tunnel.
+func (tc TunnelConfig) Matches(group, tag string) bool {
+	return (group == "" || tc.Group == group) && (tag == "" || slices.Contains(tc.Tags, tag))
+}
+
+// SocksConfig holds settings for a SOCKS proxy tunnel.
+// If Username is set, clients must authenticate. The password is left
+// out of JSON, so it isn't sent to clients or saved in the state file.
+type SocksConfig struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password" json:"-"`
+}
+
+// ReconnectConfig controls automatic reconnection of a dropped tunnel.
+// Zero durations fall back to the defaults in the tunnel package.
+type ReconnectConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	InitialDelay time.Duration `mapstructure:"initial_delay"` // Delay before the first retry (default 1s)
+	MaxDelay     time.Duration `mapstructure:"max_delay"`     // Cap on the doubling delay (default 5m)
+	Jitter       float64       `mapstructure:"jitter"`        // Random spread as a fraction of the delay, 0-1 (default 0.2)
+	MaxAttempts  int           `mapstructure:"max_attempts"`  // Give up after this many failed retries (0 = never)
 }
 
 // deriveName extracts a friendly name from a host string.
@@ -40,40 +173,70 @@ func deriveName(host string) string {
 	return host
 }
 
-// Load reads configuration from file and environment.
-// Config file locations (in order of precedence):
-//  1. ./gurren.toml
-//  2. ~/.config/gurren/config.toml
-func Load() (*Config, error) {
+// FindFile returns the config file to load, in order of precedence:
+//  1. path, if given (e.g. from --config)
+//  2. $GURREN_CONFIG
+//  3. $XDG_CONFIG_HOME/gurren/config.toml (~/.config/gurren/config.toml if unset)
+//  4. ~/gurren.toml
+//
+// A file named by path or $GURREN_CONFIG must exist. Returns "" if no
+// config file was found; the returned path is absolute.
+func FindFile(path string) (string, error) {
+	if path == "" {
+		path = os.Getenv("GURREN_CONFIG")
+	}
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("config file: %w", err)
+		}
+		return filepath.Abs(path)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to get home directory: %w", err)
+	}
+
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		configDir = filepath.Join(home, ".config")
+	}
+
+	configPaths := []string{
+		filepath.Join(configDir, "gurren", "config.toml"),
+		filepath.Join(home, "gurren.toml"),
+	}
+
+	for _, p := range configPaths {
+		if _, err := os.Stat(p); err == nil {
+			return filepath.Abs(p)
+		}
+	}
+	return "", nil
+}
+
+// Load reads configuration from the file FindFile picks for path, and
+// from the environment
+func Load(path string) (*Config, error) {
 	v := viper.New()
 
 	// Set defaults
 	v.SetDefault("auth.method", "auto")
+	v.SetDefault("auth.cache_ttl", "15m")
+	v.SetDefault("daemon.start_timeout", "30s")
+	v.SetDefault("daemon.log_level", "info")
+	v.SetDefault("daemon.log_format", "text")
+	v.SetDefault("daemon.log_max_size", 10)
+	v.SetDefault("daemon.log_max_files", 3)
 	v.SetConfigType("toml")
 
 	// Environment variables
 	v.SetEnvPrefix("GURREN")
 	v.AutomaticEnv()
 
-	// Find config file in order of precedence:
-	// 1. ~/.config/gurren/config.toml
-	// 2. ~/gurren.toml
-	home, err := os.UserHomeDir()
+	configFile, err := FindFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get home directory: %w", err)
-	}
-
-	configPaths := []string{
-		filepath.Join(home, ".config", "gurren", "config.toml"),
-		filepath.Join(home, "gurren.toml"),
-	}
-
-	var configFile string
-	for _, path := range configPaths {
-		if _, err := os.Stat(path); err == nil {
-			configFile = path
-			break
-		}
+		return nil, err
 	}
 
 	if configFile != "" {
@@ -88,6 +251,7 @@ func Load() (*Config, error) {
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("error parsing config: %w", err)
 	}
+	cfg.File = configFile
 
 	// Derive names for tunnels that don't have one
 	for i := range cfg.Tunnels {
@@ -122,6 +286,24 @@ func (c *Config) GetTunnelByName(name string) *TunnelConfig {
 	return nil
 }
 
+// AuthFor returns the auth settings for a tunnel: the global settings,
+// with the methods, keys and agent socket replaced by the tunnel's own
+// if it sets them. IdentitiesOnly applies if either sets it.
+func (c *Config) AuthFor(tc *TunnelConfig) AuthConfig {
+	a := c.Auth
+	if len(tc.Auth.MethodList()) > 0 {
+		a.Method, a.Methods = tc.Auth.Method, tc.Auth.Methods
+	}
+	if len(tc.Auth.KeyPathList()) > 0 {
+		a.KeyPath, a.KeyPaths = tc.Auth.KeyPath, tc.Auth.KeyPaths
+	}
+	if tc.Auth.AgentSocket != "" {
+		a.AgentSocket = tc.Auth.AgentSocket
+	}
+	a.IdentitiesOnly = a.IdentitiesOnly || tc.Auth.IdentitiesOnly
+	return a
+}
+
 // TunnelNames returns a list of all configured tunnel names.
 func (c *Config) TunnelNames() []string {
 	names := make([]string, len(c.Tunnels))
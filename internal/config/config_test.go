@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindFile(t *testing.T) {
+	// Paths are relative to a temporary home directory. The XDG config
+	// dir is xdg/ when set, ~/.config otherwise.
+	tests := []struct {
+		name    string
+		files   []string // Files that exist
+		flag    string   // --config
+		env     string   // $GURREN_CONFIG
+		xdg     bool     // Whether $XDG_CONFIG_HOME is set
+		want    string   // "" if no file is found
+		wantErr bool
+	}{
+		{name: "none", xdg: true, want: ""},
+		{name: "home", files: []string{"gurren.toml"}, xdg: true, want: "gurren.toml"},
+		{name: "xdg", files: []string{"xdg/gurren/config.toml"}, xdg: true, want: "xdg/gurren/config.toml"},
+		{name: "xdg unset", files: []string{".config/gurren/config.toml"}, want: ".config/gurren/config.toml"},
+		{
+			name:  "xdg over home",
+			files: []string{"xdg/gurren/config.toml", "gurren.toml"},
+			xdg:   true,
+			want:  "xdg/gurren/config.toml",
+		},
+		{
+			name:  "env over xdg and home",
+			files: []string{"env.toml", "xdg/gurren/config.toml", "gurren.toml"},
+			env:   "env.toml",
+			xdg:   true,
+			want:  "env.toml",
+		},
+		{
+			name:  "flag over everything",
+			files: []string{"flag.toml", "env.toml", "xdg/gurren/config.toml", "gurren.toml"},
+			flag:  "flag.toml",
+			env:   "env.toml",
+			xdg:   true,
+			want:  "flag.toml",
+		},
+		{name: "missing flag file", files: []string{"gurren.toml"}, flag: "flag.toml", wantErr: true},
+		{name: "missing env file", files: []string{"gurren.toml"}, env: "env.toml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			t.Setenv("XDG_CONFIG_HOME", "")
+			if tt.xdg {
+				t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+			}
+			t.Setenv("GURREN_CONFIG", "")
+			if tt.env != "" {
+				t.Setenv("GURREN_CONFIG", filepath.Join(home, tt.env))
+			}
+
+			for _, f := range tt.files {
+				path := filepath.Join(home, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			flag := ""
+			if tt.flag != "" {
+				flag = filepath.Join(home, tt.flag)
+			}
+			got, err := FindFile(flag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			want := ""
+			if tt.want != "" {
+				want = filepath.Join(home, tt.want)
+			}
+			if got != want {
+				t.Errorf("FindFile() = %q, want %q", got, want)
+			}
+		})
+	}
+}
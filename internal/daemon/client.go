@@ -153,9 +153,11 @@ func (c *Client) call(method string, params any) (Response, error) {
 	}
 }
 
-// Subscribe subscribes to status change notifications
-func (c *Client) Subscribe() error {
-	resp, err := c.call(MethodSubscribe, nil)
+// Subscribe subscribes to status change notifications. With prompts,
+// the client is also asked the prompts of tunnels no attached client
+// started.
+func (c *Client) Subscribe(prompts bool) error {
+	resp, err := c.call(MethodSubscribe, SubscribeParams{Prompts: prompts})
 	if err != nil {
 		return err
 	}
@@ -211,6 +213,41 @@ func (c *Client) TunnelStop(name string) error {
 	return nil
 }
 
+// TunnelStartGroup starts the tunnels in a group and/or with a tag,
+// waiting for each one like TunnelStart
+func (c *Client) TunnelStartGroup(group, tag string) (*TunnelStartGroupResult, error) {
+	resp, err := c.call(MethodTunnelStartGroup, TunnelGroupParams{Group: group, Tag: tag})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s", resp.Error.Message)
+	}
+
+	var result TunnelStartGroupResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+	return &result, nil
+}
+
+// TunnelStopGroup stops the tunnels in a group and/or with a tag
+func (c *Client) TunnelStopGroup(group, tag string) (*TunnelStopGroupResult, error) {
+	resp, err := c.call(MethodTunnelStopGroup, TunnelGroupParams{Group: group, Tag: tag})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s", resp.Error.Message)
+	}
+
+	var result TunnelStopGroupResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+	return &result, nil
+}
+
 // TunnelStatus gets the status of a tunnel
 func (c *Client) TunnelStatus(name string) (*TunnelStatusResult, error) {
 	resp, err := c.call(MethodTunnelStatus, TunnelStatusParams{Name: name})
@@ -228,6 +265,55 @@ func (c *Client) TunnelStatus(name string) (*TunnelStatusResult, error) {
 	return &result, nil
 }
 
+// TunnelStats gets the traffic counters of a tunnel, or of every tunnel
+// if name is empty
+func (c *Client) TunnelStats(name string) (*TunnelStatsResult, error) {
+	resp, err := c.call(MethodTunnelStats, TunnelStatsParams{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s", resp.Error.Message)
+	}
+
+	var result TunnelStatsResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+	return &result, nil
+}
+
+// TunnelLogs gets the most recent log entries of a tunnel, or of every
+// tunnel if name is empty. limit 0 gets all kept entries.
+func (c *Client) TunnelLogs(name string, limit int) (*TunnelLogsResult, error) {
+	resp, err := c.call(MethodTunnelLogs, TunnelLogsParams{Name: name, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s", resp.Error.Message)
+	}
+
+	var result TunnelLogsResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+	return &result, nil
+}
+
+// SubscribeLogs asks for new log entries of a tunnel, or of every tunnel
+// if name is empty, as tunnel.log notifications
+func (c *Client) SubscribeLogs(name string) error {
+	resp, err := c.call(MethodLogsSubscribe, TunnelLogsParams{Name: name})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("%s", resp.Error.Message)
+	}
+	return nil
+}
+
 // TunnelList lists all tunnels
 func (c *Client) TunnelList() (*TunnelListResult, error) {
 	resp, err := c.call(MethodTunnelList, nil)
@@ -246,12 +332,8 @@ func (c *Client) TunnelList() (*TunnelListResult, error) {
 }
 
 // TunnelRegister registers an ad-hoc tunnel and returns its generated name
-func (c *Client) TunnelRegister(host, remote, local string) (*TunnelRegisterResult, error) {
-	resp, err := c.call(MethodTunnelRegister, TunnelRegisterParams{
-		Host:   host,
-		Remote: remote,
-		Local:  local,
-	})
+func (c *Client) TunnelRegister(params TunnelRegisterParams) (*TunnelRegisterResult, error) {
+	resp, err := c.call(MethodTunnelRegister, params)
 	if err != nil {
 		return nil, err
 	}
@@ -266,6 +348,79 @@ func (c *Client) TunnelRegister(host, remote, local string) (*TunnelRegisterResu
 	return &result, nil
 }
 
+// AnswerHostKey answers an auth.hostKeyPrompt notification
+func (c *Client) AnswerHostKey(id string, accept bool) error {
+	resp, err := c.call(MethodHostKeyAnswer, HostKeyAnswerParams{ID: id, Accept: accept})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("%s", resp.Error.Message)
+	}
+	return nil
+}
+
+// AnswerSecret answers an auth.secretPrompt notification.
+// If cancel is true the secret is ignored and authentication with it is skipped.
+func (c *Client) AnswerSecret(id, secret string, cancel bool) error {
+	resp, err := c.call(MethodSecretAnswer, SecretAnswerParams{ID: id, Secret: secret, Cancel: cancel})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("%s", resp.Error.Message)
+	}
+	return nil
+}
+
+// AnswerChallenge answers an auth.challengePrompt notification.
+// Set cancel to abort the keyboard-interactive attempt.
+func (c *Client) AnswerChallenge(id string, answers []string, cancel bool) error {
+	resp, err := c.call(MethodChallengeAnswer, ChallengeAnswerParams{ID: id, Answers: answers, Cancel: cancel})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("%s", resp.Error.Message)
+	}
+	return nil
+}
+
+// Reload tells the daemon to reload its config file
+func (c *Client) Reload() (*ReloadResult, error) {
+	resp, err := c.call(MethodDaemonReload, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s", resp.Error.Message)
+	}
+
+	var result ReloadResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+	return &result, nil
+}
+
+// SetLogLevel changes the daemon's log level without a restart. An empty
+// level only reports the current one.
+func (c *Client) SetLogLevel(level string) (*SetLogLevelResult, error) {
+	resp, err := c.call(MethodDaemonLogLevel, SetLogLevelParams{Level: level})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s", resp.Error.Message)
+	}
+
+	var result SetLogLevelResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+	return &result, nil
+}
+
 // Shutdown tells the daemon to shut down
 func (c *Client) Shutdown() error {
 	resp, err := c.call(MethodDaemonShutdown, nil)
@@ -8,12 +8,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 
+	"github.com/JoshElias/gurren/internal/auth"
 	"github.com/JoshElias/gurren/internal/config"
 	"github.com/JoshElias/gurren/internal/tunnel"
 )
@@ -21,13 +24,28 @@ import (
 const Version = "0.1.1"
 
 type Daemon struct {
+	configMu sync.RWMutex
 	config   *config.Config
 	manager  *tunnel.Manager
 	listener net.Listener
+	metrics  *http.Server // nil unless [metrics] listen is set
+
+	// Serializes config reloads
+	reloadMu sync.Mutex
+
+	// Tunnels recorded as active in the state file
+	stateMu   sync.Mutex
+	lastState savedState
 
 	// Subscriber management
-	mu          sync.RWMutex
-	subscribers map[*subscriber]struct{}
+	mu             sync.RWMutex
+	subscribers    map[*subscriber]struct{}
+	logSubscribers map[*subscriber]string // Tunnel whose logs each wants, "" for all
+
+	// Prompts awaiting an answer from a client, keyed by prompt ID
+	promptsMu    sync.Mutex
+	prompts      map[string]chan json.RawMessage
+	nextPromptID atomic.Uint64
 
 	// Shutdown
 	ctx    context.Context
@@ -39,6 +57,14 @@ type subscriber struct {
 	conn    net.Conn
 	encoder *json.Encoder
 	mu      sync.Mutex
+	prompts bool // Answers prompts for tunnels no attached client started
+}
+
+// send writes a notification to the subscriber
+func (s *subscriber) send(n Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoder.Encode(n)
 }
 
 // New creates a new daemon instance
@@ -46,15 +72,25 @@ func New(cfg *config.Config) *Daemon {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	d := &Daemon{
-		config:      cfg,
-		manager:     tunnel.NewManager(cfg),
-		subscribers: make(map[*subscriber]struct{}),
-		ctx:         ctx,
-		cancel:      cancel,
+		config:         cfg,
+		manager:        tunnel.NewManager(cfg),
+		subscribers:    make(map[*subscriber]struct{}),
+		logSubscribers: make(map[*subscriber]string),
+		prompts:        make(map[string]chan json.RawMessage),
+		ctx:            ctx,
+		cancel:         cancel,
 	}
 
-	// Set up status change notifications
-	d.manager.SetOnChange(d.broadcastStatusChange)
+	// Set up status change notifications, and remember which tunnels
+	// are up for the next start
+	d.manager.SetOnChange(func(change tunnel.StatusChange) {
+		d.broadcastStatusChange(change)
+		d.saveState()
+	})
+	d.manager.SetOnLog(d.broadcastLog)
+
+	// Keep decrypted keys around so reconnecting doesn't re-prompt
+	auth.SetSignerCacheTTL(cfg.Auth.CacheTTL)
 
 	return d
 }
@@ -105,10 +141,22 @@ func (d *Daemon) Start() error {
 
 	// Set socket permissions
 	if err := os.Chmod(socketPath, 0o600); err != nil {
-		log.Printf("Warning: unable to set socket permissions: %v", err)
+		slog.Warn("Unable to set socket permissions", "err", err)
+	}
+
+	slog.Info("Daemon listening", "socket", socketPath)
+
+	// Tunnels are still served if metrics can't be
+	if addr := d.Config().Metrics.Listen; addr != "" {
+		if err := d.startMetrics(addr); err != nil {
+			slog.Error("Not serving metrics", "err", err)
+		}
 	}
 
-	log.Printf("Daemon listening on %s", socketPath)
+	// Pick up config changes without a restart
+	if err := d.watchConfig(); err != nil {
+		slog.Warn("Not watching config file", "err", err)
+	}
 
 	// Accept connections
 	go d.acceptLoop()
@@ -124,7 +172,7 @@ func (d *Daemon) acceptLoop() {
 			if d.ctx.Err() != nil {
 				return // Shutting down
 			}
-			log.Printf("Error accepting connection: %v", err)
+			slog.Error("Error accepting connection", "err", err)
 			continue
 		}
 
@@ -144,30 +192,40 @@ func (d *Daemon) handleConnection(conn net.Conn) {
 	reader := bufio.NewReader(conn)
 	decoder := json.NewDecoder(reader)
 
+	// Requests are handled concurrently: tunnel.start blocks until the
+	// tunnel is up, and may need prompt answers sent on this same connection
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
 	for {
 		var req Request
 		if err := decoder.Decode(&req); err != nil {
 			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
 				break
 			}
-			log.Printf("Error decoding request: %v", err)
+			slog.Error("Error decoding request", "err", err)
 			break
 		}
 
-		resp := d.handleRequest(sub, &req)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-		sub.mu.Lock()
-		if err := sub.encoder.Encode(resp); err != nil {
-			sub.mu.Unlock()
-			log.Printf("Error encoding response: %v", err)
-			break
-		}
-		sub.mu.Unlock()
+			resp := d.handleRequest(sub, &req)
+
+			sub.mu.Lock()
+			defer sub.mu.Unlock()
+			if err := sub.encoder.Encode(resp); err != nil {
+				slog.Error("Error encoding response", "err", err)
+				_ = conn.Close()
+			}
+		}()
 	}
 
 	// Remove from subscribers if subscribed
 	d.mu.Lock()
 	delete(d.subscribers, sub)
+	delete(d.logSubscribers, sub)
 	d.mu.Unlock()
 }
 
@@ -177,19 +235,35 @@ func (d *Daemon) handleRequest(sub *subscriber, req *Request) Response {
 	case MethodSubscribe:
 		return d.handleSubscribe(sub, req)
 	case MethodTunnelStart:
-		return d.handleTunnelStart(req)
+		return d.handleTunnelStart(sub, req)
 	case MethodTunnelStop:
 		return d.handleTunnelStop(req)
+	case MethodTunnelStartGroup:
+		return d.handleTunnelStartGroup(sub, req)
+	case MethodTunnelStopGroup:
+		return d.handleTunnelStopGroup(req)
 	case MethodTunnelStatus:
 		return d.handleTunnelStatus(req)
 	case MethodTunnelList:
 		return d.handleTunnelList(req)
+	case MethodTunnelStats:
+		return d.handleTunnelStats(req)
+	case MethodTunnelLogs:
+		return d.handleTunnelLogs(req)
+	case MethodLogsSubscribe:
+		return d.handleLogsSubscribe(sub, req)
 	case MethodTunnelRegister:
 		return d.handleTunnelRegister(req)
 	case MethodDaemonPing:
 		return d.handlePing(req)
+	case MethodDaemonReload:
+		return d.handleReload(req)
+	case MethodDaemonLogLevel:
+		return d.handleSetLogLevel(req)
 	case MethodDaemonShutdown:
 		return d.handleShutdown(req)
+	case MethodHostKeyAnswer, MethodSecretAnswer, MethodChallengeAnswer:
+		return d.handlePromptAnswer(req)
 	default:
 		return NewError(req.ID, ErrCodeMethodNotFound, fmt.Sprintf("unknown method: %s", req.Method))
 	}
@@ -197,21 +271,49 @@ func (d *Daemon) handleRequest(sub *subscriber, req *Request) Response {
 
 // broadcastStatusChange sends a status change notification to all subscribers
 func (d *Daemon) broadcastStatusChange(change tunnel.StatusChange) {
-	notification := NewNotification(MethodStatusChanged, StatusChangedParams{
-		Name:   change.Name,
-		Status: change.Status,
-		Error:  change.Error,
-	})
+	d.broadcast(NewNotification(MethodStatusChanged, StatusChangedParams{
+		Name:       change.Name,
+		Status:     change.Status,
+		Error:      change.Error,
+		Attempt:    change.Attempt,
+		NextRetry:  change.NextRetry,
+		ErrorPhase: change.ErrorPhase,
+		ListenAddr: change.ListenAddr,
+		Connection: change.Connection,
+		Forwards:   forwardInfos(change.Forwards),
+		Removed:    change.Removed,
+
+		TunnelLifecycle: TunnelLifecycle(change.Lifecycle),
+	}))
+}
+
+// broadcastLog sends a tunnel's new log entry to the clients following
+// its logs
+func (d *Daemon) broadcastLog(name string, e tunnel.LogEntry) {
+	notification := NewNotification(MethodTunnelLog, logEntry(name, e))
 
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
+	for sub, want := range d.logSubscribers {
+		if want != "" && want != name {
+			continue
+		}
+		if err := sub.send(notification); err != nil {
+			slog.Error("Error sending notification", "err", err)
+		}
+	}
+}
+
+// broadcast sends a notification to all subscribers
+func (d *Daemon) broadcast(notification Notification) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	for sub := range d.subscribers {
-		sub.mu.Lock()
-		if err := sub.encoder.Encode(notification); err != nil {
-			log.Printf("Error sending notification: %v", err)
+		if err := sub.send(notification); err != nil {
+			slog.Error("Error sending notification", "err", err)
 		}
-		sub.mu.Unlock()
 	}
 }
 
@@ -222,6 +324,9 @@ func (d *Daemon) Shutdown() {
 	if d.listener != nil {
 		_ = d.listener.Close()
 	}
+	if d.metrics != nil {
+		_ = d.metrics.Close()
+	}
 }
 
 // Wait blocks until the daemon context is cancelled
@@ -234,7 +339,43 @@ func (d *Daemon) Manager() *tunnel.Manager {
 	return d.manager
 }
 
-// Config returns the configuration (for handlers)
+// Config returns the current configuration (for handlers)
 func (d *Daemon) Config() *config.Config {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
 	return d.config
 }
+
+// Reload reads the config file again and applies it to the managed
+// tunnels. Subscribers are notified of the outcome either way.
+func (d *Daemon) Reload() (ReloadResult, error) {
+	d.reloadMu.Lock()
+	defer d.reloadMu.Unlock()
+
+	cfg, err := config.Load(d.Config().File)
+	if err != nil {
+		err = fmt.Errorf("error loading config: %w", err)
+		d.broadcast(NewNotification(MethodConfigReloaded, ConfigReloadedParams{Error: err.Error()}))
+		return ReloadResult{}, err
+	}
+
+	changes := d.manager.Reload(cfg)
+
+	d.configMu.Lock()
+	d.config = cfg
+	d.configMu.Unlock()
+	auth.SetSignerCacheTTL(cfg.Auth.CacheTTL)
+
+	result := ReloadResult{
+		ConfigFile:     cfg.File,
+		Added:          changes.Added,
+		Removed:        changes.Removed,
+		Updated:        changes.Updated,
+		RestartPending: changes.RestartPending,
+	}
+	slog.Info("Reloaded config", "file", cfg.File, "added", len(result.Added), "removed", len(result.Removed),
+		"updated", len(result.Updated), "restart_pending", len(result.RestartPending))
+
+	d.broadcast(NewNotification(MethodConfigReloaded, ConfigReloadedParams{ReloadResult: result}))
+	return result, nil
+}
@@ -1,26 +1,41 @@
 package daemon
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/JoshElias/gurren/internal/auth"
 	"github.com/JoshElias/gurren/internal/config"
 	"github.com/JoshElias/gurren/internal/sshconfig"
+	"github.com/JoshElias/gurren/internal/tunnel"
 )
 
 // handleSubscribe adds the client to the subscribers list
 func (d *Daemon) handleSubscribe(sub *subscriber, req *Request) Response {
+	var params SubscribeParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return NewError(req.ID, ErrCodeInvalidParams, "invalid params")
+		}
+	}
+
 	d.mu.Lock()
 	d.subscribers[sub] = struct{}{}
+	sub.prompts = params.Prompts
 	d.mu.Unlock()
 
 	return NewResult(req.ID, struct{}{})
 }
 
-// handleTunnelStart starts a tunnel
-func (d *Daemon) handleTunnelStart(req *Request) Response {
+// handleTunnelStart starts a tunnel. Any prompts raised while connecting
+// are sent to sub, the client that asked for the tunnel.
+func (d *Daemon) handleTunnelStart(sub *subscriber, req *Request) Response {
 	var params TunnelStartParams
 	if err := json.Unmarshal(req.Params, &params); err != nil {
 		return NewError(req.ID, ErrCodeInvalidParams, "invalid params")
@@ -30,39 +45,185 @@ func (d *Daemon) handleTunnelStart(req *Request) Response {
 		return NewError(req.ID, ErrCodeInvalidParams, "name is required")
 	}
 
+	result, rpcErr := d.startTunnel(sub, params.Name)
+	if rpcErr != nil {
+		return NewError(req.ID, rpcErr.Code, rpcErr.Message)
+	}
+	return NewResult(req.ID, result)
+}
+
+// startTunnel starts a tunnel by name, blocking until it is connected,
+// has failed or timed out. Prompts are sent to sub.
+func (d *Daemon) startTunnel(sub *subscriber, name string) (TunnelStatusResult, *Error) {
 	// Get tunnel config - first check manager (includes ephemeral), then config file
-	tunnelCfg := d.manager.GetConfig(params.Name)
-	if tunnelCfg == nil {
-		tunnelCfg = d.config.GetTunnelByName(params.Name)
+	var tunnelCfg *config.TunnelConfig
+	if tc, ok := d.manager.GetConfig(name); ok {
+		tunnelCfg = &tc
+	} else {
+		tunnelCfg = d.Config().GetTunnelByName(name)
 	}
 	if tunnelCfg == nil {
-		return NewError(req.ID, ErrCodeTunnelNotFound, fmt.Sprintf("tunnel %q not found", params.Name))
+		return TunnelStatusResult{}, &Error{ErrCodeTunnelNotFound, fmt.Sprintf("tunnel %q not found", name)}
+	}
+
+	hostKeyPolicy, err := tunnel.ParseHostKeyPolicy(tunnelCfg.HostKeyPolicy)
+	if err != nil {
+		return TunnelStatusResult{}, &Error{ErrCodeInvalidParams, err.Error()}
+	}
+
+	forwards, err := tunnel.ForwardsFromConfig(*tunnelCfg)
+	if err != nil {
+		return TunnelStatusResult{}, &Error{ErrCodeInvalidParams, err.Error()}
 	}
 
 	// Parse SSH host - resolves aliases from ~/.ssh/config
-	sshHost, sshUser, identityFiles := parseHost(tunnelCfg.Host)
+	resolved := parseHost(tunnelCfg.Host)
+
+	// Passwords, passphrases and host keys are confirmed by the requesting client
+	prompter := &clientPrompter{d: d, name: name, origin: sub}
+
+	// Get auth methods - the tunnel's auth settings override the global
+	// ones, and identity files from SSH config are tried after its keys.
+	// The methods tried go to the tunnel's log.
+	ac := d.Config().AuthFor(tunnelCfg)
+	lg, _ := d.manager.Log(name)
+	opts := authOptions(ac, resolved)
+	opts.Trace = func(msg string) { lg.Infof(tunnel.EventAuth, "%s", msg) }
+	authMethods, authDone, err := auth.GetAuthMethods(opts, prompter)
+	if err != nil {
+		return TunnelStatusResult{}, &Error{ErrCodeAuthRequired, fmt.Sprintf("auth error: %v", err)}
+	}
 
-	// Get auth methods - use identity files from SSH config if available
-	authMethod := d.config.Auth.Method
-	authMethods, err := auth.GetAuthMethodsWithIdentity(authMethod, identityFiles)
+	// Each jump host resolves its own address, user and identity files,
+	// with the same auth settings as the tunnel
+	jumps, err := resolveJumps(jumpHosts(tunnelCfg, resolved), ac, prompter, lg)
 	if err != nil {
-		return NewError(req.ID, ErrCodeAuthRequired, fmt.Sprintf("auth error: %v", err))
+		return TunnelStatusResult{}, &Error{ErrCodeAuthRequired, fmt.Sprintf("auth error: %v", err)}
 	}
 
-	// Start the tunnel
-	if err := d.manager.Start(params.Name, authMethods, sshHost, sshUser); err != nil {
+	t := tunnel.Tunnel{
+		SSHHost:         resolved.Address(),
+		SSHUser:         resolved.User,
+		HostKeyPolicy:   hostKeyPolicy,
+		KnownHostsFiles: resolved.KnownHostsFiles,
+		Jumps:           jumps,
+		AuthIdentity:    opts.Identity(),
+		AuthDone:        authDone,
+		ProxyCommand:    resolved.ProxyCommand,
+		Forwards:        forwards,
+		SocksUser:       tunnelCfg.Socks.Username,
+		SocksPassword:   tunnelCfg.Socks.Password,
+		ConfirmHostKey:  prompter.ConfirmHostKey,
+	}
+	t.KeepaliveInterval, t.KeepaliveCountMax = keepaliveSettings(tunnelCfg, resolved)
+
+	// Start the tunnel - blocks until it is connected, has failed or timed out
+	if err := d.manager.Start(name, t, authMethods); err != nil {
 		if strings.Contains(err.Error(), "already") {
-			return NewError(req.ID, ErrCodeTunnelActive, err.Error())
+			return TunnelStatusResult{}, &Error{ErrCodeTunnelActive, err.Error()}
 		}
-		return NewError(req.ID, ErrCodeInternal, err.Error())
+		return TunnelStatusResult{}, &Error{ErrCodeInternal, err.Error()}
 	}
 
-	status, errMsg := d.manager.Status(params.Name)
-	return NewResult(req.ID, TunnelStatusResult{
-		Name:   params.Name,
-		Status: status,
-		Error:  errMsg,
+	mt, _ := d.manager.Get(name)
+	return statusResult(name, mt), nil
+}
+
+// handleTunnelStartGroup starts every inactive tunnel matching the
+// selector, in parallel, and reports how each one did
+func (d *Daemon) handleTunnelStartGroup(sub *subscriber, req *Request) Response {
+	selected, errResp := d.selectTunnelsFor(req)
+	if errResp != nil {
+		return *errResp
+	}
+
+	results := make([]TunnelStatusResult, len(selected))
+	var wg sync.WaitGroup
+	for i, mt := range selected {
+		if mt.Status.IsActive() {
+			results[i] = statusResult(mt.Config.Name, mt)
+			continue
+		}
+		wg.Go(func() {
+			result, rpcErr := d.startTunnel(sub, mt.Config.Name)
+			if rpcErr != nil {
+				result = TunnelStatusResult{Name: mt.Config.Name, Status: tunnel.StateError, Error: rpcErr.Message}
+			}
+			results[i] = result
+		})
+	}
+	wg.Wait()
+
+	return NewResult(req.ID, TunnelStartGroupResult{Tunnels: results})
+}
+
+// handleTunnelStopGroup stops every active tunnel matching the selector
+func (d *Daemon) handleTunnelStopGroup(req *Request) Response {
+	selected, errResp := d.selectTunnelsFor(req)
+	if errResp != nil {
+		return *errResp
+	}
+
+	stopped := []string{}
+	for _, mt := range selected {
+		if !mt.Status.IsActive() {
+			continue
+		}
+		// Ignore tunnels that stopped in the meantime
+		if err := d.manager.Stop(mt.Config.Name); err == nil {
+			stopped = append(stopped, mt.Config.Name)
+		}
+	}
+
+	return NewResult(req.ID, TunnelStopGroupResult{Stopped: stopped})
+}
+
+// selectTunnelsFor returns the tunnels matching a group request's
+// selector, or an error response if the selector is empty or matches none
+func (d *Daemon) selectTunnelsFor(req *Request) ([]tunnel.ManagedTunnel, *Response) {
+	var params TunnelGroupParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		resp := NewError(req.ID, ErrCodeInvalidParams, "invalid params")
+		return nil, &resp
+	}
+
+	if params.Group == "" && params.Tag == "" {
+		resp := NewError(req.ID, ErrCodeInvalidParams, "group or tag is required")
+		return nil, &resp
+	}
+
+	selected := selectTunnels(d.manager.List(), params.Group, params.Tag)
+	if len(selected) == 0 {
+		resp := NewError(req.ID, ErrCodeTunnelNotFound, fmt.Sprintf("no tunnels %s", describeSelector(params.Group, params.Tag)))
+		return nil, &resp
+	}
+	return selected, nil
+}
+
+// selectTunnels returns the tunnels in group with tag, sorted by name
+func selectTunnels(tunnels []tunnel.ManagedTunnel, group, tag string) []tunnel.ManagedTunnel {
+	var selected []tunnel.ManagedTunnel
+	for _, mt := range tunnels {
+		if mt.Config.Matches(group, tag) {
+			selected = append(selected, mt)
+		}
+	}
+	slices.SortFunc(selected, func(a, b tunnel.ManagedTunnel) int {
+		return strings.Compare(a.Config.Name, b.Config.Name)
 	})
+	return selected
+}
+
+// describeSelector describes a group/tag selector for messages
+func describeSelector(group, tag string) string {
+	switch {
+	case group != "" && tag != "":
+		return fmt.Sprintf("in group %q with tag %q", group, tag)
+	case group != "":
+		return fmt.Sprintf("in group %q", group)
+	default:
+		return fmt.Sprintf("with tag %q", tag)
+	}
 }
 
 // handleTunnelStop stops a running tunnel
@@ -100,16 +261,126 @@ func (d *Daemon) handleTunnelStatus(req *Request) Response {
 		return NewError(req.ID, ErrCodeInvalidParams, "name is required")
 	}
 
-	status, errMsg := d.manager.Status(params.Name)
-	if errMsg == "tunnel not found" {
-		return NewError(req.ID, ErrCodeTunnelNotFound, errMsg)
+	mt, ok := d.manager.Get(params.Name)
+	if !ok {
+		return NewError(req.ID, ErrCodeTunnelNotFound, "tunnel not found")
 	}
 
-	return NewResult(req.ID, TunnelStatusResult{
-		Name:   params.Name,
-		Status: status,
-		Error:  errMsg,
-	})
+	return NewResult(req.ID, statusResult(params.Name, mt))
+}
+
+// statusResult builds a tunnel.status style result from a tunnel snapshot
+func statusResult(name string, mt tunnel.ManagedTunnel) TunnelStatusResult {
+	status := mt.Status
+	if status == "" {
+		status = tunnel.StateDisconnected
+	}
+	return TunnelStatusResult{
+		Name:       name,
+		Status:     status,
+		Error:      mt.Error,
+		ErrorPhase: mt.ErrorPhase,
+		ListenAddr: mt.ListenAddr,
+
+		TunnelLifecycle: TunnelLifecycle(mt.Lifecycle),
+	}
+}
+
+// forwardInfos converts forward statuses for the protocol
+func forwardInfos(statuses []tunnel.ForwardStatus) []ForwardInfo {
+	infos := make([]ForwardInfo, len(statuses))
+	for i, fs := range statuses {
+		infos[i] = ForwardInfo(fs)
+	}
+	return infos
+}
+
+// handleTunnelStats returns the traffic counters of one or all tunnels
+func (d *Daemon) handleTunnelStats(req *Request) Response {
+	var params TunnelStatsParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return NewError(req.ID, ErrCodeInvalidParams, "invalid params")
+		}
+	}
+
+	result := TunnelStatsResult{Tunnels: make(map[string]TunnelStats)}
+	if params.Name != "" {
+		mt, ok := d.manager.Get(params.Name)
+		if !ok {
+			return NewError(req.ID, ErrCodeTunnelNotFound, "tunnel not found")
+		}
+		result.Tunnels[params.Name] = TunnelStats(mt.Stats)
+		return NewResult(req.ID, result)
+	}
+
+	for _, mt := range d.manager.List() {
+		result.Tunnels[mt.Config.Name] = TunnelStats(mt.Stats)
+	}
+	return NewResult(req.ID, result)
+}
+
+// handleTunnelLogs returns the recent log entries of one or all tunnels,
+// oldest first
+func (d *Daemon) handleTunnelLogs(req *Request) Response {
+	var params TunnelLogsParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return NewError(req.ID, ErrCodeInvalidParams, "invalid params")
+		}
+	}
+
+	names := []string{params.Name}
+	if params.Name == "" {
+		names = nil
+		for _, mt := range d.manager.List() {
+			names = append(names, mt.Config.Name)
+		}
+	}
+
+	entries := []LogEntry{}
+	for _, name := range names {
+		logs, ok := d.manager.Logs(name)
+		if !ok {
+			return NewError(req.ID, ErrCodeTunnelNotFound, "tunnel not found")
+		}
+		for _, e := range logs {
+			entries = append(entries, logEntry(name, e))
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b LogEntry) int { return a.Time.Compare(b.Time) })
+	if params.Limit > 0 && len(entries) > params.Limit {
+		entries = entries[len(entries)-params.Limit:]
+	}
+
+	return NewResult(req.ID, TunnelLogsResult{Entries: entries})
+}
+
+// handleLogsSubscribe sends the client new log entries of one or all
+// tunnels as tunnel.log notifications
+func (d *Daemon) handleLogsSubscribe(sub *subscriber, req *Request) Response {
+	var params TunnelLogsParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return NewError(req.ID, ErrCodeInvalidParams, "invalid params")
+		}
+	}
+	if params.Name != "" {
+		if _, ok := d.manager.Get(params.Name); !ok {
+			return NewError(req.ID, ErrCodeTunnelNotFound, "tunnel not found")
+		}
+	}
+
+	d.mu.Lock()
+	d.logSubscribers[sub] = params.Name
+	d.mu.Unlock()
+
+	return NewResult(req.ID, struct{}{})
+}
+
+// logEntry converts a tunnel log entry for the protocol
+func logEntry(name string, e tunnel.LogEntry) LogEntry {
+	return LogEntry{Name: name, Time: e.Time, Level: e.Level, Event: e.Event, Message: e.Message}
 }
 
 // handleTunnelList returns all tunnels with their status
@@ -119,11 +390,22 @@ func (d *Daemon) handleTunnelList(req *Request) Response {
 	tunnels := make([]TunnelInfo, len(managed))
 	for i, mt := range managed {
 		tunnels[i] = TunnelInfo{
-			Name:      mt.Config.Name,
-			Status:    mt.Status,
-			Error:     mt.Error,
-			Ephemeral: mt.Ephemeral,
-			Config:    mt.Config,
+			Name:       mt.Config.Name,
+			Status:     mt.Status,
+			Error:      mt.Error,
+			Ephemeral:  mt.Ephemeral,
+			Attempt:    mt.Attempt,
+			NextRetry:  mt.NextRetry,
+			ErrorPhase: mt.ErrorPhase,
+			ListenAddr: mt.ListenAddr,
+			Connection: mt.Connection,
+			Forwards:   forwardInfos(mt.Forwards),
+			Jumps:      jumpHosts(&mt.Config, parseHost(mt.Config.Host)),
+			Stats:      TunnelStats(mt.Stats),
+			Config:     mt.Config,
+
+			RestartPending:  mt.RestartPending,
+			TunnelLifecycle: TunnelLifecycle(mt.Lifecycle),
 		}
 	}
 
@@ -137,14 +419,30 @@ func (d *Daemon) handleTunnelRegister(req *Request) Response {
 		return NewError(req.ID, ErrCodeInvalidParams, "invalid params")
 	}
 
-	if params.Host == "" || params.Remote == "" || params.Local == "" {
+	kind, err := tunnel.ParseKind(params.Type)
+	if err != nil {
+		return NewError(req.ID, ErrCodeInvalidParams, err.Error())
+	}
+
+	if kind == tunnel.KindSocks {
+		if params.Host == "" || params.Local == "" {
+			return NewError(req.ID, ErrCodeInvalidParams, "host and local are required")
+		}
+	} else if params.Host == "" || params.Remote == "" || params.Local == "" {
 		return NewError(req.ID, ErrCodeInvalidParams, "host, remote, and local are required")
 	}
 
+	if err := auth.ValidateMethods([]string{params.Auth}); err != nil {
+		return NewError(req.ID, ErrCodeInvalidParams, err.Error())
+	}
+
 	cfg := config.TunnelConfig{
+		Type:   params.Type,
 		Host:   params.Host,
 		Remote: params.Remote,
 		Local:  params.Local,
+		Jump:   params.Jump,
+		Auth:   config.AuthConfig{Method: params.Auth},
 	}
 
 	name, err := d.manager.Register(cfg)
@@ -155,9 +453,63 @@ func (d *Daemon) handleTunnelRegister(req *Request) Response {
 	return NewResult(req.ID, TunnelRegisterResult{Name: name})
 }
 
-// handlePing returns the daemon version
+// handlePromptAnswer delivers a client's answer to a pending prompt
+func (d *Daemon) handlePromptAnswer(req *Request) Response {
+	var params struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return NewError(req.ID, ErrCodeInvalidParams, "invalid params")
+	}
+
+	if params.ID == "" {
+		return NewError(req.ID, ErrCodeInvalidParams, "id is required")
+	}
+
+	if !d.resolvePrompt(params.ID, req.Params) {
+		return NewError(req.ID, ErrCodePromptNotFound, fmt.Sprintf("prompt %q not found or already answered", params.ID))
+	}
+
+	return NewResult(req.ID, struct{}{})
+}
+
+// handlePing returns the daemon version and config file
 func (d *Daemon) handlePing(req *Request) Response {
-	return NewResult(req.ID, PingResult{Version: Version})
+	return NewResult(req.ID, PingResult{Version: Version, ConfigFile: d.Config().File})
+}
+
+// handleReload reloads the config file
+func (d *Daemon) handleReload(req *Request) Response {
+	result, err := d.Reload()
+	if err != nil {
+		return NewError(req.ID, ErrCodeInternal, err.Error())
+	}
+	return NewResult(req.ID, result)
+}
+
+// handleSetLogLevel changes the service log level until the daemon
+// restarts, leaving tunnels running
+func (d *Daemon) handleSetLogLevel(req *Request) Response {
+	var params SetLogLevelParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return NewError(req.ID, ErrCodeInvalidParams, "invalid params")
+		}
+	}
+
+	previous := logLevel.Level()
+	if params.Level == "" {
+		return NewResult(req.ID, SetLogLevelResult{Level: levelName(previous), Previous: levelName(previous)})
+	}
+
+	level, err := ParseLogLevel(params.Level)
+	if err != nil {
+		return NewError(req.ID, ErrCodeInvalidParams, err.Error())
+	}
+	logLevel.Set(level)
+	slog.Log(context.Background(), max(level, slog.LevelInfo), "Log level changed", "level", levelName(level), "previous", levelName(previous))
+
+	return NewResult(req.ID, SetLogLevelResult{Level: levelName(level), Previous: levelName(previous)})
 }
 
 // handleShutdown stops the daemon
@@ -170,33 +522,105 @@ func (d *Daemon) handleShutdown(req *Request) Response {
 	return NewResult(req.ID, struct{}{})
 }
 
+// keepaliveSettings picks the keepalive interval and count for a tunnel:
+// the tunnel config wins, then ServerAliveInterval/ServerAliveCountMax
+// from ssh config, then the defaults
+func keepaliveSettings(tc *config.TunnelConfig, resolved *sshconfig.ResolvedHost) (time.Duration, int) {
+	interval := tunnel.DefaultKeepaliveInterval
+	if resolved.ServerAliveInterval > 0 {
+		interval = resolved.ServerAliveInterval
+	}
+	if tc.KeepaliveInterval != 0 {
+		interval = max(tc.KeepaliveInterval, 0)
+	}
+
+	countMax := tunnel.DefaultKeepaliveCountMax
+	if resolved.ServerAliveCountMax > 0 {
+		countMax = resolved.ServerAliveCountMax
+	}
+	if tc.KeepaliveCountMax > 0 {
+		countMax = tc.KeepaliveCountMax
+	}
+
+	return interval, countMax
+}
+
+// jumpHosts returns the jump hosts a tunnel connects through:
+// its jump list if set, otherwise ProxyJump from ssh config
+func jumpHosts(tc *config.TunnelConfig, resolved *sshconfig.ResolvedHost) []string {
+	if len(tc.Jump) > 0 {
+		return tc.Jump
+	}
+	return resolved.ProxyJump
+}
+
+// resolveJumps resolves each jump host like a tunnel host, with its own
+// identity files and the tunnel's auth settings. The methods tried go to lg.
+func resolveJumps(hosts []string, ac config.AuthConfig, prompter auth.Prompter, lg *tunnel.Log) ([]tunnel.Hop, error) {
+	hops := make([]tunnel.Hop, len(hosts))
+	for i, host := range hosts {
+		resolved := parseHost(host)
+		opts := authOptions(ac, resolved)
+		opts.Trace = func(msg string) { lg.Infof(tunnel.EventAuth, "Jump host %s: %s", host, msg) }
+		authMethods, authDone, err := auth.GetAuthMethods(opts, prompter)
+		if err != nil {
+			return nil, fmt.Errorf("jump host %s: %w", host, err)
+		}
+		hops[i] = tunnel.Hop{
+			Name:            host,
+			SSHHost:         resolved.Address(),
+			SSHUser:         resolved.User,
+			ProxyCommand:    resolved.ProxyCommand,
+			KnownHostsFiles: resolved.KnownHostsFiles,
+			AuthMethods:     authMethods,
+			AuthIdentity:    opts.Identity(),
+			AuthDone:        authDone,
+		}
+	}
+	return hops, nil
+}
+
+// authOptions converts auth settings to auth options for a host.
+// Configured keys are tried before identity files from ssh config.
+func authOptions(ac config.AuthConfig, resolved *sshconfig.ResolvedHost) auth.Options {
+	return auth.Options{
+		Methods:        ac.MethodList(),
+		KeyPaths:       append(ac.KeyPathList(), resolved.IdentityFiles...),
+		IdentitiesOnly: ac.IdentitiesOnly,
+		AgentSocket:    ac.AgentSocket,
+		CertFiles:      resolved.CertificateFiles,
+	}
+}
+
 // parseHost parses a host string like "user@host:port" or "host"
 // It first attempts to resolve the host from ~/.ssh/config, falling back
 // to manual parsing if not found in SSH config.
-// Returns (host:port, user, identityFiles)
-func parseHost(host string) (string, string, []string) {
+func parseHost(host string) *sshconfig.ResolvedHost {
 	// Check if host contains @ or : - if so, it's an explicit address, not an alias
 	// Parse it manually instead of looking up in SSH config
 	if strings.Contains(host, "@") || strings.Contains(host, ":") {
-		user := ""
+		resolved := &sshconfig.ResolvedHost{
+			Port:            "22",
+			KnownHostsFiles: sshconfig.DefaultKnownHostsFiles(),
+		}
 		addr := host
 
 		// Extract user if present
 		if u, a, ok := strings.Cut(host, "@"); ok {
-			user = u
+			resolved.User = u
 			addr = a
 		}
 
-		// Add default port if not present
-		if !strings.Contains(addr, ":") {
-			addr = addr + ":22"
+		// Extract port if present
+		if h, p, ok := strings.Cut(addr, ":"); ok {
+			addr = h
+			resolved.Port = p
 		}
+		resolved.Hostname = addr
 
-		return addr, user, nil
+		return resolved
 	}
 
 	// Try to resolve from SSH config
-	resolved := sshconfig.Resolve(host)
-
-	return resolved.Address(), resolved.User, resolved.IdentityFiles
+	return sshconfig.Resolve(host)
 }
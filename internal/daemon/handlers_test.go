@@ -1,7 +1,19 @@
 package daemon
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/JoshElias/gurren/internal/config"
+	"github.com/JoshElias/gurren/internal/sshconfig"
+	"github.com/JoshElias/gurren/internal/tunnel"
 )
 
 func TestParseHost(t *testing.T) {
@@ -52,7 +64,8 @@ func TestParseHost(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			addr, user, _ := parseHost(tt.input)
+			resolved := parseHost(tt.input)
+			addr, user := resolved.Address(), resolved.User
 
 			if addr != tt.expectedAddr {
 				t.Errorf("parseHost(%q) addr = %q, want %q", tt.input, addr, tt.expectedAddr)
@@ -70,7 +83,7 @@ func TestParseHost_SSHAlias(t *testing.T) {
 	// This test uses the real SSH config, so we can't predict exact values,
 	// but we can verify the function doesn't panic and returns valid data
 	t.Run("ssh alias format", func(t *testing.T) {
-		addr, _, _ := parseHost("some-alias")
+		addr := parseHost("some-alias").Address()
 
 		// Should have some address with a port
 		if addr == "" {
@@ -81,3 +94,173 @@ func TestParseHost_SSHAlias(t *testing.T) {
 		// We can't test the exact value without mocking SSH config
 	})
 }
+
+func TestKeepaliveSettings(t *testing.T) {
+	tests := []struct {
+		name         string
+		tunnel       config.TunnelConfig
+		resolved     sshconfig.ResolvedHost
+		wantInterval time.Duration
+		wantCountMax int
+	}{
+		{
+			name:         "defaults",
+			wantInterval: tunnel.DefaultKeepaliveInterval,
+			wantCountMax: tunnel.DefaultKeepaliveCountMax,
+		},
+		{
+			name:         "ssh config",
+			resolved:     sshconfig.ResolvedHost{ServerAliveInterval: 15 * time.Second, ServerAliveCountMax: 5},
+			wantInterval: 15 * time.Second,
+			wantCountMax: 5,
+		},
+		{
+			name:         "tunnel config overrides ssh config",
+			tunnel:       config.TunnelConfig{KeepaliveInterval: 10 * time.Second, KeepaliveCountMax: 2},
+			resolved:     sshconfig.ResolvedHost{ServerAliveInterval: 15 * time.Second, ServerAliveCountMax: 5},
+			wantInterval: 10 * time.Second,
+			wantCountMax: 2,
+		},
+		{
+			name:         "negative interval disables",
+			tunnel:       config.TunnelConfig{KeepaliveInterval: -1},
+			resolved:     sshconfig.ResolvedHost{ServerAliveInterval: 15 * time.Second},
+			wantInterval: 0,
+			wantCountMax: tunnel.DefaultKeepaliveCountMax,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interval, countMax := keepaliveSettings(&tt.tunnel, &tt.resolved)
+			if interval != tt.wantInterval {
+				t.Errorf("interval = %s, want %s", interval, tt.wantInterval)
+			}
+			if countMax != tt.wantCountMax {
+				t.Errorf("countMax = %d, want %d", countMax, tt.wantCountMax)
+			}
+		})
+	}
+}
+
+func TestSelectTunnels(t *testing.T) {
+	tunnels := []tunnel.ManagedTunnel{
+		{Config: config.TunnelConfig{Name: "staging-web", Group: "staging", Tags: []string{"web"}}},
+		{Config: config.TunnelConfig{Name: "staging-db", Group: "staging", Tags: []string{"db"}}},
+		{Config: config.TunnelConfig{Name: "prod-db", Group: "prod-eu", Tags: []string{"db", "critical"}}},
+		{Config: config.TunnelConfig{Name: "adhoc"}, Ephemeral: true},
+	}
+
+	tests := []struct {
+		name  string
+		group string
+		tag   string
+		want  []string
+	}{
+		{name: "group", group: "staging", want: []string{"staging-db", "staging-web"}},
+		{name: "tag", tag: "db", want: []string{"prod-db", "staging-db"}},
+		{name: "group and tag", group: "staging", tag: "db", want: []string{"staging-db"}},
+		{name: "no match", group: "dev", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, mt := range selectTunnels(tunnels, tt.group, tt.tag) {
+				got = append(got, mt.Config.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("selectTunnels(%q, %q) = %v, want %v", tt.group, tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartTunnel_ConcurrentReload(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_STATE_HOME", dir)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	// Nothing listens on port 1, so every start fails fast
+	file := filepath.Join(dir, "config.toml")
+	write := func(remote string) {
+		t.Helper()
+		content := fmt.Sprintf(`[daemon]
+start_timeout = "10ms"
+
+[[tunnels]]
+name = "db"
+host = "user@127.0.0.1:1"
+remote = %q
+local = "127.0.0.1:0"
+tags = ["db"]
+`, remote)
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("db:5432")
+
+	cfg, err := config.Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(cfg)
+	defer d.Shutdown()
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := range 50 {
+			write(fmt.Sprintf("db:%d", 5432+i%2))
+			if _, err := d.Reload(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	})
+	wg.Go(func() {
+		for range 50 {
+			_, _ = d.startTunnel(nil, "db")
+		}
+	})
+	wg.Wait()
+
+	_ = d.manager.Stop("db")
+}
+
+func TestTunnelList_HidesSocksPassword(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	tc := config.TunnelConfig{Name: "proxy", Type: "socks", Host: "bastion", Local: "127.0.0.1:1080",
+		Socks: config.SocksConfig{Username: "alice", Password: "hunter2"}}
+	d := New(&config.Config{Tunnels: []config.TunnelConfig{tc}})
+	defer d.Shutdown()
+
+	data, err := json.Marshal(d.handleTunnelList(&Request{ID: "1", Method: MethodTunnelList}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("tunnel list includes the SOCKS password: %s", data)
+	}
+	if !strings.Contains(string(data), "alice") {
+		t.Errorf("tunnel list is missing the SOCKS username: %s", data)
+	}
+
+	// Nor is it saved for ad-hoc tunnels
+	path, err := StatePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeState(path, savedState{Ephemeral: []config.TunnelConfig{tc}}); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(saved), "hunter2") {
+		t.Errorf("state file includes the SOCKS password: %s", saved)
+	}
+}
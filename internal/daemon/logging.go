@@ -0,0 +1,303 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+
+	"github.com/JoshElias/gurren/internal/config"
+)
+
+// logLevel is the level of every daemon log handler, changed at runtime
+// by daemon.setLogLevel
+var logLevel slog.LevelVar
+
+// ParseLogLevel parses a log level name. Empty means info.
+func ParseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid log level %q (want debug, info, warn or error)", s)
+	}
+}
+
+// levelName returns the config name of a log level
+func levelName(l slog.Level) string {
+	return strings.ToLower(l.String())
+}
+
+// LogPath returns the path to the daemon log file
+func LogPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "daemon.log"), nil
+}
+
+// SetupLogging sends the daemon's logs to the log file, rotated by size.
+// Under systemd they also go to the journal with syslog priorities, and
+// on a terminal (service start --foreground) to stderr. The returned
+// closer closes the log file.
+func SetupLogging(cfg config.DaemonConfig) (io.Closer, error) {
+	level, err := ParseLogLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	logLevel.Set(level)
+
+	path, err := LogPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := openRotatingFile(path, int64(cfg.LogMaxSize)<<20, cfg.LogMaxFiles)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open log file: %w", err)
+	}
+
+	opts := &slog.HandlerOptions{Level: &logLevel}
+	var handlers fanoutHandler
+	switch cfg.LogFormat {
+	case "", "text":
+		handlers = append(handlers, slog.NewTextHandler(file, opts))
+	case "json":
+		handlers = append(handlers, slog.NewJSONHandler(file, opts))
+	default:
+		_ = file.Close()
+		return nil, fmt.Errorf("invalid log format %q (want text or json)", cfg.LogFormat)
+	}
+
+	// systemd sets JOURNAL_STREAM when stderr goes to the journal
+	if os.Getenv("JOURNAL_STREAM") != "" {
+		handlers = append(handlers, newJournalHandler(os.Stderr, &logLevel))
+	} else if term.IsTerminal(int(os.Stderr.Fd())) {
+		handlers = append(handlers, slog.NewTextHandler(os.Stderr, opts))
+	}
+
+	slog.SetDefault(slog.New(handlers))
+	return file, nil
+}
+
+// rotatingFile is a log file that is renamed to <path>.1 once it reaches
+// maxSize, shifting older files up to <path>.<maxFiles>
+type rotatingFile struct {
+	path     string
+	maxSize  int64 // 0 never rotates
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// openRotatingFile opens the log file for appending
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+// Write appends to the file, rotating it first if p would take it past
+// the maximum size
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file aside and starts a new one. The caller
+// must hold r.mu.
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	_ = os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if r.maxFiles > 0 {
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// Close closes the file
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// journalHandler writes records as single lines with a syslog priority
+// prefix (e.g. "<4>" for warnings), which journald turns into the
+// entry's priority. The journal adds its own timestamps.
+type journalHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Leveler
+	attrs string // Formatted attrs from WithAttrs
+	group string // Key prefix from WithGroup
+}
+
+func newJournalHandler(w io.Writer, level slog.Leveler) *journalHandler {
+	return &journalHandler{mu: &sync.Mutex{}, w: w, level: level}
+}
+
+func (h *journalHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *journalHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(journalPriority(r.Level))
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.group, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *journalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		appendAttr(&b, h.group, a)
+	}
+	h2 := *h
+	h2.attrs += b.String()
+	return &h2
+}
+
+func (h *journalHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group += name + "."
+	return &h2
+}
+
+// journalPriority returns the sd-daemon priority prefix for a level
+func journalPriority(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return "<3>"
+	case l >= slog.LevelWarn:
+		return "<4>"
+	case l >= slog.LevelInfo:
+		return "<6>"
+	default:
+		return "<7>"
+	}
+}
+
+// appendAttr writes " key=value", flattening groups into dotted keys
+func appendAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			appendAttr(b, prefix+a.Key+".", ga)
+		}
+		return
+	}
+
+	v := a.Value.String()
+	if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+		v = strconv.Quote(v)
+	}
+	b.WriteString(" " + prefix + a.Key + "=" + v)
+}
+
+// fanoutHandler passes records to several handlers
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, l) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range f {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
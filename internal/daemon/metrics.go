@@ -0,0 +1,153 @@
+package daemon
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JoshElias/gurren/internal/tunnel"
+)
+
+// metricStates are the values of the state label of gurren_tunnel_state
+var metricStates = []tunnel.State{
+	tunnel.StateDisconnected,
+	tunnel.StateConnecting,
+	tunnel.StateConnected,
+	tunnel.StateReconnecting,
+	tunnel.StateError,
+	tunnel.StateHostKeyMismatch,
+}
+
+// tunnelMetric is a metric with one sample per tunnel
+type tunnelMetric struct {
+	name  string
+	help  string
+	kind  string // gauge or counter
+	value func(mt *tunnel.ManagedTunnel, now time.Time) float64
+}
+
+var tunnelMetrics = []tunnelMetric{
+	{"gurren_tunnel_up", "Whether the tunnel is connected.", "gauge",
+		func(mt *tunnel.ManagedTunnel, _ time.Time) float64 {
+			return boolValue(mt.Status == tunnel.StateConnected)
+		}},
+	{"gurren_tunnel_uptime_seconds", "How long the tunnel has been connected, 0 if it isn't.", "gauge",
+		func(mt *tunnel.ManagedTunnel, now time.Time) float64 {
+			if mt.Lifecycle.ConnectedAt.IsZero() {
+				return 0
+			}
+			return now.Sub(mt.Lifecycle.ConnectedAt).Seconds()
+		}},
+	{"gurren_tunnel_restarts_total", "Times the tunnel came up again after its first connection, including manual restarts.", "counter",
+		func(mt *tunnel.ManagedTunnel, _ time.Time) float64 { return float64(mt.Lifecycle.Restarts) }},
+	{"gurren_tunnel_reconnects_total", "Times the tunnel came back up by reconnecting automatically after its connection dropped.", "counter",
+		func(mt *tunnel.ManagedTunnel, _ time.Time) float64 { return float64(mt.Lifecycle.Reconnects) }},
+	{"gurren_tunnel_reconnect_attempt", "Reconnect attempts since the connection dropped, 0 once connected.", "gauge",
+		func(mt *tunnel.ManagedTunnel, _ time.Time) float64 { return float64(mt.Attempt) }},
+	{"gurren_tunnel_received_bytes_total", "Bytes received through the SSH connection.", "counter",
+		func(mt *tunnel.ManagedTunnel, _ time.Time) float64 { return float64(mt.Stats.BytesIn) }},
+	{"gurren_tunnel_sent_bytes_total", "Bytes sent through the SSH connection.", "counter",
+		func(mt *tunnel.ManagedTunnel, _ time.Time) float64 { return float64(mt.Stats.BytesOut) }},
+	{"gurren_tunnel_active_connections", "Connections being forwarded now.", "gauge",
+		func(mt *tunnel.ManagedTunnel, _ time.Time) float64 { return float64(mt.Stats.ActiveConns) }},
+	{"gurren_tunnel_connections_total", "Connections accepted for forwarding.", "counter",
+		func(mt *tunnel.ManagedTunnel, _ time.Time) float64 { return float64(mt.Stats.TotalConns) }},
+	{"gurren_tunnel_failed_dials_total", "Forwarded connections whose target couldn't be reached.", "counter",
+		func(mt *tunnel.ManagedTunnel, _ time.Time) float64 { return float64(mt.Stats.FailedDials) }},
+	{"gurren_tunnel_auth_failures_total", "SSH connections the server or a jump host refused to authenticate.", "counter",
+		func(mt *tunnel.ManagedTunnel, _ time.Time) float64 { return float64(mt.Stats.AuthFailures) }},
+	{"gurren_tunnel_last_handshake_seconds", "How long the latest SSH handshake took, up to host key verification. Authentication is not included.", "gauge",
+		func(mt *tunnel.ManagedTunnel, _ time.Time) float64 { return mt.Stats.LastHandshake.Seconds() }},
+}
+
+// startMetrics serves Prometheus metrics on addr until the daemon shuts down
+func (d *Daemon) startMetrics(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to listen for metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", d.handleMetrics)
+	d.metrics = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := d.metrics.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server failed", "err", err)
+		}
+	}()
+
+	slog.Info("Serving metrics", "addr", listener.Addr().String())
+	return nil
+}
+
+// handleMetrics writes every tunnel's metrics in the Prometheus text format
+func (d *Daemon) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := writeMetrics(w, d.manager.List(), time.Now()); err != nil {
+		slog.Debug("Error writing metrics", "err", err)
+	}
+}
+
+// writeMetrics writes the metrics of the given tunnels, labelled with
+// their name, group and host
+func writeMetrics(out io.Writer, tunnels []tunnel.ManagedTunnel, now time.Time) error {
+	slices.SortFunc(tunnels, func(a, b tunnel.ManagedTunnel) int { return strings.Compare(a.Config.Name, b.Config.Name) })
+	labels := make([]string, len(tunnels))
+	for i, mt := range tunnels {
+		labels[i] = fmt.Sprintf(`tunnel="%s",group="%s",host="%s"`,
+			escapeLabel(mt.Config.Name), escapeLabel(mt.Config.Group), escapeLabel(mt.Config.Host))
+	}
+
+	w := bufio.NewWriter(out)
+
+	writeHeader(w, "gurren_tunnel_state", "Current state of the tunnel, 1 for the state it is in.", "gauge")
+	for i, mt := range tunnels {
+		for _, state := range metricStates {
+			fmt.Fprintf(w, "gurren_tunnel_state{%s,state=\"%s\"} %s\n", labels[i], state, formatValue(boolValue(mt.Status == state)))
+		}
+	}
+
+	for _, m := range tunnelMetrics {
+		writeHeader(w, m.name, m.help, m.kind)
+		for i := range tunnels {
+			fmt.Fprintf(w, "%s{%s} %s\n", m.name, labels[i], formatValue(m.value(&tunnels[i], now)))
+		}
+	}
+
+	writeHeader(w, "gurren_tunnel_handshake_seconds", "Time SSH handshakes took, up to host key verification. Authentication is not included.", "summary")
+	for i, mt := range tunnels {
+		fmt.Fprintf(w, "gurren_tunnel_handshake_seconds_sum{%s} %s\n", labels[i], formatValue(mt.Stats.HandshakeTime.Seconds()))
+		fmt.Fprintf(w, "gurren_tunnel_handshake_seconds_count{%s} %d\n", labels[i], mt.Stats.Handshakes)
+	}
+
+	return w.Flush()
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoshElias/gurren/internal/config"
+	"github.com/JoshElias/gurren/internal/tunnel"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	now := time.Now()
+	tunnels := []tunnel.ManagedTunnel{
+		{
+			Config:  config.TunnelConfig{Name: "web", Group: "staging", Host: "bastion"},
+			Status:  tunnel.StateReconnecting,
+			Attempt: 2,
+		},
+		{
+			Config: config.TunnelConfig{Name: "db", Group: "prod", Host: `admin@"db"`},
+			Status: tunnel.StateConnected,
+			Stats: tunnel.StatsSnapshot{
+				BytesIn:       2048,
+				ActiveConns:   3,
+				Handshakes:    2,
+				HandshakeTime: 300 * time.Millisecond,
+				LastHandshake: 100 * time.Millisecond,
+				AuthFailures:  1,
+			},
+			Lifecycle: tunnel.Lifecycle{ConnectedAt: now.Add(-90 * time.Second), Restarts: 4, Reconnects: 3},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeMetrics(&buf, tunnels, now); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	db := `tunnel="db",group="prod",host="admin@\"db\""`
+	web := `tunnel="web",group="staging",host="bastion"`
+	for _, want := range []string{
+		"# TYPE gurren_tunnel_up gauge\ngurren_tunnel_up{" + db + "} 1\ngurren_tunnel_up{" + web + "} 0\n",
+		"gurren_tunnel_state{" + db + `,state="connected"} 1`,
+		"gurren_tunnel_state{" + web + `,state="connected"} 0`,
+		"gurren_tunnel_state{" + web + `,state="reconnecting"} 1`,
+		"gurren_tunnel_uptime_seconds{" + db + "} 90\n",
+		"gurren_tunnel_restarts_total{" + db + "} 4\n",
+		"gurren_tunnel_reconnects_total{" + db + "} 3\n",
+		"gurren_tunnel_reconnect_attempt{" + web + "} 2\n",
+		"gurren_tunnel_received_bytes_total{" + db + "} 2048\n",
+		"gurren_tunnel_active_connections{" + db + "} 3\n",
+		"gurren_tunnel_auth_failures_total{" + db + "} 1\n",
+		"gurren_tunnel_last_handshake_seconds{" + db + "} 0.1\n",
+		"# TYPE gurren_tunnel_handshake_seconds summary\n",
+		"gurren_tunnel_handshake_seconds_sum{" + db + "} 0.3\n",
+		"gurren_tunnel_handshake_seconds_count{" + db + "} 2\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+	if t.Failed() {
+		t.Logf("metrics:\n%s", out)
+	}
+}
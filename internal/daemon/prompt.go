@@ -0,0 +1,193 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/JoshElias/gurren/internal/auth"
+	"github.com/JoshElias/gurren/internal/tunnel"
+)
+
+// promptTimeout is how long the daemon waits for a client to answer a prompt
+const promptTimeout = 2 * time.Minute
+
+// errPromptCancelled is returned when the user dismisses a prompt
+var errPromptCancelled = errors.New("prompt cancelled")
+
+// errNoPromptClient is returned when a prompt has no attached client to answer it
+var errNoPromptClient = errors.New("no client attached to answer the prompt")
+
+// ask sends a prompt notification to the client that started the tunnel
+// (or to the subscribers that take prompts if that client has gone away)
+// and waits for the answer. The returned params are the raw answer
+// request params.
+func (d *Daemon) ask(ctx context.Context, origin *subscriber, id string, notification Notification) (json.RawMessage, error) {
+	answerCh := make(chan json.RawMessage, 1)
+
+	d.promptsMu.Lock()
+	d.prompts[id] = answerCh
+	d.promptsMu.Unlock()
+
+	defer func() {
+		d.promptsMu.Lock()
+		delete(d.prompts, id)
+		d.promptsMu.Unlock()
+
+		d.broadcast(NewNotification(MethodPromptResolved, PromptResolvedParams{ID: id}))
+	}()
+
+	if sent := d.sendPrompt(origin, notification); sent == 0 {
+		return nil, errNoPromptClient
+	}
+
+	timer := time.NewTimer(promptTimeout)
+	defer timer.Stop()
+
+	select {
+	case answer := <-answerCh:
+		return answer, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("no answer within %s", promptTimeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-d.ctx.Done():
+		return nil, d.ctx.Err()
+	}
+}
+
+// sendPrompt delivers a prompt to origin if it is still subscribed,
+// otherwise to the subscribers that asked for prompts (the TUI), so an
+// unrelated gurren connect isn't asked. Returns the number of clients
+// reached.
+func (d *Daemon) sendPrompt(origin *subscriber, notification Notification) int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if _, ok := d.subscribers[origin]; ok {
+		if err := origin.send(notification); err != nil {
+			return 0
+		}
+		return 1
+	}
+
+	sent := 0
+	for sub := range d.subscribers {
+		if !sub.prompts {
+			continue
+		}
+		if err := sub.send(notification); err == nil {
+			sent++
+		}
+	}
+	return sent
+}
+
+// resolvePrompt hands an answer to the waiting prompt.
+// Returns false if the prompt doesn't exist (already answered or timed out).
+func (d *Daemon) resolvePrompt(id string, answer json.RawMessage) bool {
+	d.promptsMu.Lock()
+	answerCh, ok := d.prompts[id]
+	delete(d.prompts, id)
+	d.promptsMu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	answerCh <- answer
+	return true
+}
+
+// newPromptID returns a unique prompt ID
+func (d *Daemon) newPromptID() string {
+	return strconv.FormatUint(d.nextPromptID.Add(1), 10)
+}
+
+// clientPrompter relays prompts raised while connecting a tunnel to the
+// client that started it. It implements auth.Prompter. Prompts are
+// dismissed when the tunnel is stopped.
+type clientPrompter struct {
+	d      *Daemon
+	name   string
+	origin *subscriber
+}
+
+var _ auth.Prompter = (*clientPrompter)(nil)
+
+// Secret asks the client for a password or passphrase
+func (p *clientPrompter) Secret(prompt string) (string, error) {
+	id := p.d.newPromptID()
+	raw, err := p.d.ask(p.d.manager.Context(p.name), p.origin, id, NewNotification(MethodSecretPrompt, SecretPromptParams{
+		ID:     id,
+		Name:   p.name,
+		Prompt: prompt,
+	}))
+	if err != nil {
+		return "", err
+	}
+
+	var answer SecretAnswerParams
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return "", fmt.Errorf("invalid answer: %w", err)
+	}
+	if answer.Cancel {
+		return "", errPromptCancelled
+	}
+	return answer.Secret, nil
+}
+
+// Challenge asks the client to answer a keyboard-interactive challenge
+func (p *clientPrompter) Challenge(name, instruction string, questions []string, echos []bool) ([]string, error) {
+	params := ChallengePromptParams{
+		ID:          p.d.newPromptID(),
+		Name:        p.name,
+		Title:       name,
+		Instruction: instruction,
+	}
+	for i, q := range questions {
+		params.Questions = append(params.Questions, ChallengeQuestion{Prompt: q, Echo: echos[i]})
+	}
+
+	raw, err := p.d.ask(p.d.manager.Context(p.name), p.origin, params.ID, NewNotification(MethodChallengePrompt, params))
+	if err != nil {
+		return nil, err
+	}
+
+	var answer ChallengeAnswerParams
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return nil, fmt.Errorf("invalid answer: %w", err)
+	}
+	if answer.Cancel {
+		return nil, errPromptCancelled
+	}
+	if len(answer.Answers) != len(questions) {
+		return nil, fmt.Errorf("expected %d answers, got %d", len(questions), len(answer.Answers))
+	}
+	return answer.Answers, nil
+}
+
+// ConfirmHostKey asks the client whether to trust an unknown host key.
+// It satisfies tunnel.HostKeyConfirmFunc.
+func (p *clientPrompter) ConfirmHostKey(ctx context.Context, hk tunnel.HostKeyPrompt) (bool, error) {
+	id := p.d.newPromptID()
+	raw, err := p.d.ask(ctx, p.origin, id, NewNotification(MethodHostKeyPrompt, HostKeyPromptParams{
+		ID:          id,
+		Name:        p.name,
+		Host:        hk.Host,
+		KeyType:     hk.KeyType,
+		Fingerprint: hk.Fingerprint,
+	}))
+	if err != nil {
+		return false, err
+	}
+
+	var answer HostKeyAnswerParams
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return false, fmt.Errorf("invalid answer: %w", err)
+	}
+	return answer.Accept, nil
+}
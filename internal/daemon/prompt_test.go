@@ -0,0 +1,176 @@
+package daemon
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/JoshElias/gurren/internal/config"
+	"github.com/JoshElias/gurren/internal/tunnel"
+)
+
+// newPasswordServer starts an SSH server that only accepts password auth
+func newPasswordServer(t *testing.T) string {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCfg := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) { return nil, nil },
+	}
+	serverCfg.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, chans, reqs, err := ssh.NewServerConn(conn, serverCfg)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for range chans {
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestClientPrompter_StopDismissesSecret(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	addr := newPasswordServer(t)
+
+	tc := config.TunnelConfig{Name: "db", Host: addr, Remote: "db:5432", Local: "127.0.0.1:0"}
+	d := New(&config.Config{
+		Daemon:  config.DaemonConfig{StartTimeout: 10 * time.Millisecond},
+		Tunnels: []config.TunnelConfig{tc},
+	})
+	defer d.Shutdown()
+
+	// A client attached to answer prompts, which never does
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+	sub := &subscriber{conn: server, encoder: json.NewEncoder(server)}
+	d.subscribers[sub] = struct{}{}
+
+	prompted := make(chan struct{})
+	go func() {
+		decoder := json.NewDecoder(client)
+		for {
+			var n Notification
+			if err := decoder.Decode(&n); err != nil {
+				return
+			}
+			if n.Method == MethodSecretPrompt {
+				close(prompted)
+			}
+		}
+	}()
+
+	forwards, err := tunnel.ForwardsFromConfig(tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompter := &clientPrompter{d: d, name: tc.Name, origin: sub}
+	tun := tunnel.Tunnel{SSHHost: addr, HostKeyPolicy: tunnel.HostKeyOff, Forwards: forwards}
+	password := ssh.PasswordCallback(func() (string, error) { return prompter.Secret("Password: ") })
+	if err := d.manager.Start(tc.Name, tun, []ssh.AuthMethod{password}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-prompted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no secret prompt sent")
+	}
+
+	if err := d.manager.Stop(tc.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mt, _ := d.manager.Get(tc.Name)
+		if mt.Status == tunnel.StateDisconnected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("tunnel still %s after stop", mt.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	d.promptsMu.Lock()
+	pending := len(d.prompts)
+	d.promptsMu.Unlock()
+	if pending != 0 {
+		t.Errorf("%d prompts still pending after stop", pending)
+	}
+}
+
+func TestSendPrompt_NoOriginOnlyReachesPromptSubscribers(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	d := New(&config.Config{})
+	defer d.Shutdown()
+
+	// The TUI takes prompts for tunnels nobody attached started,
+	// a gurren connect for another tunnel doesn't
+	received := make(map[string]chan Notification)
+	for _, name := range []string{"tui", "connect"} {
+		client, server := net.Pipe()
+		defer func() { _ = client.Close() }()
+		sub := &subscriber{conn: server, encoder: json.NewEncoder(server), prompts: name == "tui"}
+		d.subscribers[sub] = struct{}{}
+
+		ch := make(chan Notification, 1)
+		received[name] = ch
+		go func() {
+			var n Notification
+			if err := json.NewDecoder(client).Decode(&n); err == nil {
+				ch <- n
+			}
+		}()
+	}
+
+	notification := NewNotification(MethodSecretPrompt, SecretPromptParams{ID: "1", Name: "restored", Prompt: "Password: "})
+	if sent := d.sendPrompt(nil, notification); sent != 1 {
+		t.Errorf("sendPrompt() reached %d clients, want 1", sent)
+	}
+
+	select {
+	case n := <-received["tui"]:
+		if n.Method != MethodSecretPrompt {
+			t.Errorf("tui got %s, want %s", n.Method, MethodSecretPrompt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("tui wasn't sent the prompt")
+	}
+	select {
+	case n := <-received["connect"]:
+		t.Errorf("connect was sent %s", n.Method)
+	default:
+	}
+}
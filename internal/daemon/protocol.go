@@ -3,6 +3,7 @@ package daemon
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/JoshElias/gurren/internal/config"
 	"github.com/JoshElias/gurren/internal/tunnel"
@@ -10,17 +11,33 @@ import (
 
 // Method constants for the JSON-RPC style protocol
 const (
-	MethodTunnelStart    = "tunnel.start"
-	MethodTunnelStop     = "tunnel.stop"
-	MethodTunnelStatus   = "tunnel.status"
-	MethodTunnelList     = "tunnel.list"
-	MethodTunnelRegister = "tunnel.register"
-	MethodDaemonPing     = "daemon.ping"
-	MethodDaemonShutdown = "daemon.shutdown"
-	MethodSubscribe      = "subscribe"
+	MethodTunnelStart      = "tunnel.start"
+	MethodTunnelStop       = "tunnel.stop"
+	MethodTunnelStatus     = "tunnel.status"
+	MethodTunnelList       = "tunnel.list"
+	MethodTunnelRegister   = "tunnel.register"
+	MethodTunnelStartGroup = "tunnel.startGroup"
+	MethodTunnelStopGroup  = "tunnel.stopGroup"
+	MethodTunnelStats      = "tunnel.stats"
+	MethodTunnelLogs       = "tunnel.logs"
+	MethodLogsSubscribe    = "tunnel.logsSubscribe"
+	MethodDaemonPing       = "daemon.ping"
+	MethodDaemonShutdown   = "daemon.shutdown"
+	MethodDaemonReload     = "daemon.reload"
+	MethodDaemonLogLevel   = "daemon.setLogLevel"
+	MethodSubscribe        = "subscribe"
+	MethodHostKeyAnswer    = "auth.hostKeyAnswer"
+	MethodSecretAnswer     = "auth.secretAnswer"
+	MethodChallengeAnswer  = "auth.challengeAnswer"
 
 	// Notification methods (server -> client)
-	MethodStatusChanged = "tunnel.statusChanged"
+	MethodStatusChanged   = "tunnel.statusChanged"
+	MethodHostKeyPrompt   = "auth.hostKeyPrompt"
+	MethodSecretPrompt    = "auth.secretPrompt"
+	MethodChallengePrompt = "auth.challengePrompt"
+	MethodPromptResolved  = "auth.promptResolved"
+	MethodConfigReloaded  = "daemon.configReloaded"
+	MethodTunnelLog       = "tunnel.log"
 )
 
 // Request is a message from client to daemon
@@ -58,10 +75,16 @@ const (
 	ErrCodeTunnelActive   = 1002
 	ErrCodeTunnelInactive = 1003
 	ErrCodeAuthRequired   = 1004
+	ErrCodePromptNotFound = 1005
 )
 
 // --- Request Parameters ---
 
+// SubscribeParams are parameters for subscribe
+type SubscribeParams struct {
+	Prompts bool `json:"prompts,omitempty"` // Also receive prompts for tunnels no attached client started (e.g. restored ones)
+}
+
 // TunnelStartParams are parameters for tunnel.start
 type TunnelStartParams struct {
 	Name string `json:"name"`
@@ -77,11 +100,22 @@ type TunnelStatusParams struct {
 	Name string `json:"name"`
 }
 
+// TunnelGroupParams are parameters for tunnel.startGroup and
+// tunnel.stopGroup. Tunnels in Group that have Tag are selected; either
+// may be empty, but not both.
+type TunnelGroupParams struct {
+	Group string `json:"group,omitempty"`
+	Tag   string `json:"tag,omitempty"`
+}
+
 // TunnelRegisterParams are parameters for tunnel.register (ad-hoc tunnels)
 type TunnelRegisterParams struct {
-	Host   string `json:"host"`   // SSH host (user@host:port)
-	Remote string `json:"remote"` // Remote address (host:port)
-	Local  string `json:"local"`  // Local bind address (host:port)
+	Type   string   `json:"type,omitempty"`   // "local" (default), "socks" or "remote"
+	Host   string   `json:"host"`             // SSH host (user@host:port)
+	Remote string   `json:"remote,omitempty"` // Remote address (host:port), not used for socks
+	Local  string   `json:"local"`            // Local bind address (host:port)
+	Jump   []string `json:"jump,omitempty"`   // Jump hosts to connect through, in order
+	Auth   string   `json:"auth,omitempty"`   // Auth method, overriding the global one
 }
 
 // TunnelRegisterResult is the result of tunnel.register
@@ -89,22 +123,77 @@ type TunnelRegisterResult struct {
 	Name string `json:"name"` // Generated name for the tunnel
 }
 
+// HostKeyAnswerParams are parameters for auth.hostKeyAnswer
+type HostKeyAnswerParams struct {
+	ID     string `json:"id"`     // ID from the auth.hostKeyPrompt notification
+	Accept bool   `json:"accept"` // true to trust the key and continue connecting
+}
+
+// SecretAnswerParams are parameters for auth.secretAnswer
+type SecretAnswerParams struct {
+	ID     string `json:"id"`               // ID from the auth.secretPrompt notification
+	Secret string `json:"secret,omitempty"` // The password or passphrase
+	Cancel bool   `json:"cancel,omitempty"` // true if the user declined to answer
+}
+
+// ChallengeAnswerParams are parameters for auth.challengeAnswer
+type ChallengeAnswerParams struct {
+	ID      string   `json:"id"`                // ID from the auth.challengePrompt notification
+	Answers []string `json:"answers,omitempty"` // One answer per question, in order
+	Cancel  bool     `json:"cancel,omitempty"`  // true if the user declined to answer
+}
+
 // --- Response Results ---
 
 // TunnelStatusResult is the result of tunnel.status
 type TunnelStatusResult struct {
-	Name   string       `json:"name"`
-	Status tunnel.State `json:"status"`
-	Error  string       `json:"error,omitempty"`
+	Name       string       `json:"name"`
+	Status     tunnel.State `json:"status"`
+	Error      string       `json:"error,omitempty"`
+	ErrorPhase tunnel.Phase `json:"errorPhase,omitempty"` // dial, handshake, auth or listen
+	ListenAddr string       `json:"listenAddr,omitempty"` // Bound address, e.g. the server-assigned port of a remote forward
+	TunnelLifecycle
+}
+
+// TunnelStartGroupResult is the result of tunnel.startGroup
+type TunnelStartGroupResult struct {
+	Tunnels []TunnelStatusResult `json:"tunnels"` // Outcome for each selected tunnel, sorted by name
+}
+
+// TunnelStopGroupResult is the result of tunnel.stopGroup
+type TunnelStopGroupResult struct {
+	Stopped []string `json:"stopped"` // Selected tunnels that were active and are now stopping
 }
 
 // TunnelInfo represents a tunnel in the list response
 type TunnelInfo struct {
-	Name      string              `json:"name"`
-	Status    tunnel.State        `json:"status"`
-	Error     string              `json:"error,omitempty"`
-	Ephemeral bool                `json:"ephemeral"`
-	Config    config.TunnelConfig `json:"config"`
+	Name       string              `json:"name"`
+	Status     tunnel.State        `json:"status"`
+	Error      string              `json:"error,omitempty"`
+	Ephemeral  bool                `json:"ephemeral"`
+	Attempt    int                 `json:"attempt,omitempty"`  // Reconnect attempt number
+	NextRetry  time.Time           `json:"nextRetry,omitzero"` // When the next reconnect attempt is due
+	ErrorPhase tunnel.Phase        `json:"errorPhase,omitempty"`
+	ListenAddr string              `json:"listenAddr,omitempty"` // Bound address while connected
+	Connection string              `json:"connection,omitempty"` // SSH connection in use, shared by tunnels with the same value
+	Forwards   []ForwardInfo       `json:"forwards,omitempty"`   // State of each forward
+	Jumps      []string            `json:"jumps,omitempty"`      // Jump hosts the tunnel connects through
+	Stats      TunnelStats         `json:"stats"`
+	Config     config.TunnelConfig `json:"config"`
+	TunnelLifecycle
+
+	// The config changed while the tunnel was active; the change applies once it stops
+	RestartPending bool `json:"restartPending,omitempty"`
+}
+
+// ForwardInfo is the state of one forward of a tunnel
+type ForwardInfo struct {
+	Name       string `json:"name"`
+	Type       string `json:"type,omitempty"`
+	Local      string `json:"local"`
+	Remote     string `json:"remote,omitempty"`
+	ListenAddr string `json:"listenAddr,omitempty"` // Bound address while connected
+	Error      string `json:"error,omitempty"`      // Why the forward failed to start
 }
 
 // TunnelListResult is the result of tunnel.list
@@ -112,18 +201,155 @@ type TunnelListResult struct {
 	Tunnels []TunnelInfo `json:"tunnels"`
 }
 
+// TunnelLifecycle records when a tunnel came up and why it last went
+// down, since the service started
+type TunnelLifecycle struct {
+	StartedAt            time.Time `json:"startedAt,omitzero"`             // When the tunnel was last started
+	ConnectedAt          time.Time `json:"connectedAt,omitzero"`           // When the current connection came up, unset while not connected
+	LastErrorAt          time.Time `json:"lastErrorAt,omitzero"`           // When the tunnel last failed
+	LastDisconnectReason string    `json:"lastDisconnectReason,omitempty"` // Why the last connection went down
+	Restarts             int       `json:"restarts"`                       // Times the tunnel came up again after its first connection
+	Reconnects           int       `json:"reconnects"`                     // Of those, times it came back up by reconnecting automatically
+}
+
+// TunnelStatsParams are parameters for tunnel.stats
+type TunnelStatsParams struct {
+	Name string `json:"name,omitempty"` // Tunnel to report on, all tunnels if empty
+}
+
+// TunnelStats are a tunnel's traffic and connection counters since the
+// service started
+type TunnelStats struct {
+	BytesIn      uint64    `json:"bytesIn"`               // Received through the SSH connection
+	BytesOut     uint64    `json:"bytesOut"`              // Sent through the SSH connection
+	ActiveConns  int64     `json:"activeConns"`           // Connections being forwarded now
+	TotalConns   uint64    `json:"totalConns"`            // Connections accepted
+	FailedDials  uint64    `json:"failedDials"`           // Connections whose target couldn't be reached
+	LastActivity time.Time `json:"lastActivity,omitzero"` // When a connection was last accepted or carried data
+
+	Handshakes    uint64        `json:"handshakes"`    // SSH connections made to the server
+	HandshakeTime time.Duration `json:"handshakeTime"` // Nanoseconds spent in them, up to the host key, summed
+	LastHandshake time.Duration `json:"lastHandshake"` // Nanoseconds the latest one took
+	AuthFailures  uint64        `json:"authFailures"`  // Connections the server or a jump host refused to authenticate
+}
+
+// TunnelStatsResult is the result of tunnel.stats
+type TunnelStatsResult struct {
+	Tunnels map[string]TunnelStats `json:"tunnels"` // Keyed by tunnel name
+}
+
+// TunnelLogsParams are parameters for tunnel.logs and tunnel.logsSubscribe
+type TunnelLogsParams struct {
+	Name  string `json:"name,omitempty"`  // Tunnel to get logs for, all tunnels if empty
+	Limit int    `json:"limit,omitempty"` // Most recent entries to return, all kept entries if 0 (tunnel.logs only)
+}
+
+// LogEntry is an event from a tunnel's log. It is also the params of
+// the tunnel.log notification.
+type LogEntry struct {
+	Name    string       `json:"name"` // Tunnel name
+	Time    time.Time    `json:"time"`
+	Level   tunnel.Level `json:"level"` // info, warn or error
+	Event   tunnel.Event `json:"event"` // e.g. dial, auth, accept or copy
+	Message string       `json:"message"`
+}
+
+// TunnelLogsResult is the result of tunnel.logs
+type TunnelLogsResult struct {
+	Entries []LogEntry `json:"entries"` // Oldest first
+}
+
 // PingResult is the result of daemon.ping
 type PingResult struct {
-	Version string `json:"version"`
+	Version    string `json:"version"`
+	ConfigFile string `json:"configFile"` // Config file the daemon loaded, "" if none
+}
+
+// ReloadResult is the result of daemon.reload
+type ReloadResult struct {
+	ConfigFile     string   `json:"configFile"`
+	Added          []string `json:"added,omitempty"`          // New tunnels
+	Removed        []string `json:"removed,omitempty"`        // Deleted tunnels, gone now
+	Updated        []string `json:"updated,omitempty"`        // Changed inactive tunnels, using the new config now
+	RestartPending []string `json:"restartPending,omitempty"` // Changed or deleted active tunnels, updated once they stop
+}
+
+// SetLogLevelParams are parameters for daemon.setLogLevel
+type SetLogLevelParams struct {
+	Level string `json:"level,omitempty"` // debug, info, warn or error; empty only reports the current level
+}
+
+// SetLogLevelResult is the result of daemon.setLogLevel
+type SetLogLevelResult struct {
+	Level    string `json:"level"`    // Level now in use
+	Previous string `json:"previous"` // Level before the call
 }
 
 // --- Notification Parameters ---
 
 // StatusChangedParams are parameters for tunnel.statusChanged notification
 type StatusChangedParams struct {
-	Name   string       `json:"name"`
-	Status tunnel.State `json:"status"`
-	Error  string       `json:"error,omitempty"`
+	Name       string        `json:"name"`
+	Status     tunnel.State  `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	Attempt    int           `json:"attempt,omitempty"`  // Reconnect attempt number
+	NextRetry  time.Time     `json:"nextRetry,omitzero"` // When the next reconnect attempt is due
+	ErrorPhase tunnel.Phase  `json:"errorPhase,omitempty"`
+	ListenAddr string        `json:"listenAddr,omitempty"` // Bound address, set when connected
+	Connection string        `json:"connection,omitempty"` // SSH connection in use, set when connected
+	Forwards   []ForwardInfo `json:"forwards,omitempty"`
+	Removed    bool          `json:"removed,omitempty"` // Dropped from the config on stopping; clients should forget it
+	TunnelLifecycle
+}
+
+// ConfigReloadedParams are parameters for daemon.configReloaded
+// notification, sent after the config file is reloaded so clients can
+// refresh their tunnel list
+type ConfigReloadedParams struct {
+	ReloadResult
+	Error string `json:"error,omitempty"` // Why the reload failed; the old config stays in use
+}
+
+// HostKeyPromptParams are parameters for auth.hostKeyPrompt notification.
+// The client answers with auth.hostKeyAnswer using the same ID.
+type HostKeyPromptParams struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`        // Tunnel name
+	Host        string `json:"host"`        // SSH server address (host:port)
+	KeyType     string `json:"keyType"`     // e.g. "ssh-ed25519"
+	Fingerprint string `json:"fingerprint"` // SHA256 fingerprint
+}
+
+// SecretPromptParams are parameters for auth.secretPrompt notification.
+// The client should read the answer without echoing it, then reply with
+// auth.secretAnswer using the same ID.
+type SecretPromptParams struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`   // Tunnel name
+	Prompt string `json:"prompt"` // e.g. "Enter passphrase for key '~/.ssh/id_ed25519': "
+}
+
+// ChallengePromptParams are parameters for auth.challengePrompt notification,
+// sent for each keyboard-interactive challenge from the server. The client
+// answers every question, then replies with auth.challengeAnswer using the same ID.
+type ChallengePromptParams struct {
+	ID          string              `json:"id"`
+	Name        string              `json:"name"`                  // Tunnel name
+	Title       string              `json:"title,omitempty"`       // Challenge name sent by the server
+	Instruction string              `json:"instruction,omitempty"` // Instructions sent by the server
+	Questions   []ChallengeQuestion `json:"questions"`
+}
+
+// ChallengeQuestion is a single question in a keyboard-interactive challenge
+type ChallengeQuestion struct {
+	Prompt string `json:"prompt"` // e.g. "Verification code: "
+	Echo   bool   `json:"echo"`   // false if the answer must not be shown
+}
+
+// PromptResolvedParams are parameters for auth.promptResolved notification,
+// sent once a prompt is answered or times out so other clients can dismiss it
+type PromptResolvedParams struct {
+	ID string `json:"id"`
 }
 
 // Helper functions for creating responses
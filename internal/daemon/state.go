@@ -0,0 +1,192 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/JoshElias/gurren/internal/config"
+	"github.com/JoshElias/gurren/internal/tunnel"
+)
+
+// savedState is what the daemon keeps between runs: the tunnels that
+// were up, so they can be brought back after a restart
+type savedState struct {
+	Active    []string              `json:"active"`              // Configured tunnels that were up
+	Ephemeral []config.TunnelConfig `json:"ephemeral,omitempty"` // Ad-hoc tunnels that were up
+}
+
+// StatePath returns the path to the daemon state file
+func StatePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.json"), nil
+}
+
+// stateDir returns the directory for files the daemon keeps between
+// runs, creating it if needed
+func stateDir() (string, error) {
+	// Use XDG_STATE_HOME if available, otherwise ~/.local/state
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("unable to get home directory: %w", err)
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+
+	dir := filepath.Join(stateHome, "gurren")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("unable to create state directory: %w", err)
+	}
+	return dir, nil
+}
+
+// loadState reads the state file. A missing file is an empty state.
+func loadState(path string) (savedState, error) {
+	var state savedState
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// writeState replaces the state file, so a crash never leaves it half written
+func writeState(path string, state savedState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// saveState records the active tunnels in the state file. Nothing is
+// recorded once shutting down, so the tunnels that were up before the
+// shutdown are the ones restored on the next start.
+func (d *Daemon) saveState() {
+	d.stateMu.Lock()
+	defer d.stateMu.Unlock()
+
+	if d.ctx.Err() != nil {
+		return
+	}
+
+	state := savedState{Active: []string{}}
+	for _, mt := range d.manager.List() {
+		if !mt.Status.IsActive() {
+			continue
+		}
+		if mt.Ephemeral {
+			state.Ephemeral = append(state.Ephemeral, mt.Config)
+		} else {
+			state.Active = append(state.Active, mt.Config.Name)
+		}
+	}
+	slices.Sort(state.Active)
+	slices.SortFunc(state.Ephemeral, func(a, b config.TunnelConfig) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	if reflect.DeepEqual(state, d.lastState) {
+		return
+	}
+
+	path, err := StatePath()
+	if err == nil {
+		err = writeState(path, state)
+	}
+	if err != nil {
+		slog.Warn("Unable to save state", "err", err)
+		return
+	}
+	d.lastState = state
+}
+
+// Restore starts, in the background, the tunnels marked autostart and
+// those that were up when the daemon last stopped. Ad-hoc tunnels are
+// restored too if the config asks for it.
+func (d *Daemon) Restore() {
+	cfg := d.Config()
+
+	var names []string
+	for _, tc := range cfg.Tunnels {
+		if tc.Autostart {
+			names = append(names, tc.Name)
+		}
+	}
+
+	state := d.previousState()
+	for _, name := range state.Active {
+		if cfg.GetTunnelByName(name) == nil {
+			slog.Info("Not restoring tunnel: no longer configured", "tunnel", name)
+			continue
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	if cfg.Daemon.RestoreEphemeral {
+		for _, tc := range state.Ephemeral {
+			name, err := d.manager.Register(tc)
+			if err != nil {
+				slog.Warn("Not restoring ad-hoc tunnel", "tunnel", tc.Name, "err", err)
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		slog.Info("Restoring tunnel", "tunnel", name)
+		go func() {
+			// Prompts go to any client that is attached
+			result, rpcErr := d.startTunnel(nil, name)
+			switch {
+			case rpcErr != nil:
+				slog.Error("Unable to restore tunnel", "tunnel", name, "err", rpcErr.Message)
+			case result.Status.IsError():
+				slog.Error("Unable to restore tunnel", "tunnel", name, "err", result.Error)
+			case result.Status != tunnel.StateConnected:
+				slog.Warn("Restored tunnel is not connected yet", "tunnel", name, "status", result.Status)
+			}
+		}()
+	}
+}
+
+// previousState reads the state left by the last run, logging any problem
+func (d *Daemon) previousState() savedState {
+	path, err := StatePath()
+	if err != nil {
+		slog.Warn("Unable to read state", "err", err)
+		return savedState{}
+	}
+	state, err := loadState(path)
+	if err != nil {
+		slog.Warn("Unable to read state", "err", err)
+	}
+	return state
+}
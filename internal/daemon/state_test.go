@@ -0,0 +1,47 @@
+package daemon
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/JoshElias/gurren/internal/config"
+)
+
+func TestState_RoundTrip(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	path, err := StatePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No state file yet
+	state, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+	if len(state.Active) != 0 || len(state.Ephemeral) != 0 {
+		t.Errorf("loadState() = %+v, want empty state", state)
+	}
+
+	want := savedState{
+		Active:    []string{"prod-db", "staging-web"},
+		Ephemeral: []config.TunnelConfig{{Name: "brave_turing", Host: "bastion", Remote: "db:5432", Local: "localhost:5432"}},
+	}
+	if err := writeState(path, want); err != nil {
+		t.Fatalf("writeState() error = %v", err)
+	}
+
+	got, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadState() = %+v, want %+v", got, want)
+	}
+
+	if matches, _ := filepath.Glob(path + ".tmp"); len(matches) > 0 {
+		t.Error("temporary state file left behind")
+	}
+}
@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay batches the burst of events an editor makes when saving
+const reloadDelay = 250 * time.Millisecond
+
+// watchConfig reloads the config whenever the config file changes.
+// The directory is watched rather than the file, since editors often
+// save by replacing the file.
+func (d *Daemon) watchConfig() error {
+	file := d.Config().File
+	if file == "" {
+		return fmt.Errorf("no config file loaded")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		_ = watcher.Close()
+		return err
+	}
+
+	go func() {
+		<-d.ctx.Done()
+		_ = watcher.Close()
+	}()
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != file || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDelay, func() {
+					if _, err := d.Reload(); err != nil {
+						slog.Error("Config reload failed", "err", err)
+					}
+				})
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("Config watcher error", "err", err)
+			}
+		}
+	}()
+
+	return nil
+}
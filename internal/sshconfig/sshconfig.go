@@ -5,7 +5,9 @@ package sshconfig
 import (
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/kevinburke/ssh_config"
 )
@@ -20,6 +22,21 @@ type ResolvedHost struct {
 	Port string
 	// IdentityFiles are the private key paths to use (from IdentityFile directives)
 	IdentityFiles []string
+	// CertificateFiles are the user certificates to use with the identity
+	// files (from CertificateFile directives)
+	CertificateFiles []string
+	// KnownHostsFiles are the known_hosts paths used to verify host keys
+	// (UserKnownHostsFile entries first, then GlobalKnownHostsFile)
+	KnownHostsFiles []string
+	// ServerAliveInterval is how often to send keepalives (0 if unset)
+	ServerAliveInterval time.Duration
+	// ServerAliveCountMax is how many keepalives may go unanswered (0 if unset)
+	ServerAliveCountMax int
+	// ProxyJump lists the jump hosts to connect through, in order (from ProxyJump directive)
+	ProxyJump []string
+	// ProxyCommand is the command to connect through, with %h, %p, %r and %n
+	// expanded (from ProxyCommand directive, empty if unset)
+	ProxyCommand string
 }
 
 // Resolve looks up a host alias in ~/.ssh/config and /etc/ssh/ssh_config
@@ -67,12 +84,107 @@ func Resolve(alias string) *ResolvedHost {
 		identityFiles[i] = expandPath(f)
 	}
 
+	certificateFiles := ssh_config.GetAll(alias, "CertificateFile")
+	for i, f := range certificateFiles {
+		certificateFiles[i] = expandPath(f)
+	}
+
+	// Get known_hosts files - falls back to the OpenSSH defaults
+	userKnownHosts, _ := ssh_config.GetStrict(alias, "UserKnownHostsFile")
+	globalKnownHosts, _ := ssh_config.GetStrict(alias, "GlobalKnownHostsFile")
+
+	// Get keepalive settings - invalid values are treated as unset
+	aliveInterval, _ := ssh_config.GetStrict(alias, "ServerAliveInterval")
+	aliveCountMax, _ := ssh_config.GetStrict(alias, "ServerAliveCountMax")
+	intervalSecs, _ := strconv.Atoi(aliveInterval)
+	countMax, _ := strconv.Atoi(aliveCountMax)
+
+	// Get jump hosts
+	proxyJump, _ := ssh_config.GetStrict(alias, "ProxyJump")
+	proxyCommand, _ := ssh_config.GetStrict(alias, "ProxyCommand")
+	if strings.EqualFold(proxyCommand, "none") {
+		proxyCommand = ""
+	}
+
 	return &ResolvedHost{
-		Hostname:      hostname,
-		User:          user,
-		Port:          port,
-		IdentityFiles: identityFiles,
+		Hostname:            hostname,
+		User:                user,
+		Port:                port,
+		IdentityFiles:       identityFiles,
+		CertificateFiles:    certificateFiles,
+		KnownHostsFiles:     append(splitPaths(userKnownHosts), splitPaths(globalKnownHosts)...),
+		ServerAliveInterval: time.Duration(intervalSecs) * time.Second,
+		ServerAliveCountMax: countMax,
+		ProxyJump:           ParseProxyJump(proxyJump),
+		ProxyCommand:        expandTokens(proxyCommand, alias, hostname, port, user),
+	}
+}
+
+// expandTokens expands the ssh_config tokens usable in ProxyCommand:
+// %h (hostname), %p (port), %r (user), %n (alias as given) and %%.
+// Unknown tokens are left as they are.
+func expandTokens(value, alias, hostname, port, user string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'h':
+			b.WriteString(hostname)
+		case 'p':
+			b.WriteString(port)
+		case 'r':
+			b.WriteString(user)
+		case 'n':
+			b.WriteString(alias)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// ParseProxyJump splits a ProxyJump value like "bastion,user@jump:2222"
+// into its hops. "none" means no jump hosts.
+func ParseProxyJump(value string) []string {
+	if strings.EqualFold(strings.TrimSpace(value), "none") {
+		return nil
+	}
+	var hops []string
+	for _, hop := range strings.Split(value, ",") {
+		if hop = strings.TrimSpace(hop); hop != "" {
+			hops = append(hops, strings.TrimPrefix(hop, "ssh://"))
+		}
+	}
+	return hops
+}
+
+// DefaultKnownHostsFiles returns the OpenSSH default known_hosts paths,
+// for hosts that are not resolved through SSH config.
+func DefaultKnownHostsFiles() []string {
+	return append(
+		splitPaths(ssh_config.Default("UserKnownHostsFile")),
+		splitPaths(ssh_config.Default("GlobalKnownHostsFile"))...,
+	)
+}
+
+// splitPaths splits a whitespace-separated list of paths (as used by the
+// *KnownHostsFile directives), expanding ~ and dropping "none"
+func splitPaths(value string) []string {
+	var paths []string
+	for _, f := range strings.Fields(value) {
+		if strings.EqualFold(f, "none") {
+			continue
+		}
+		paths = append(paths, expandPath(f))
 	}
+	return paths
 }
 
 // IsFromConfig returns true if the alias was found in SSH config
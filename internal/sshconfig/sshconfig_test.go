@@ -3,6 +3,7 @@ package sshconfig
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/kevinburke/ssh_config"
@@ -188,3 +189,42 @@ func TestExpandPath(t *testing.T) {
 		})
 	}
 }
+
+func TestParseProxyJump(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"none", nil},
+		{"bastion", []string{"bastion"}},
+		{"bastion-a, user@jump-b:2222", []string{"bastion-a", "user@jump-b:2222"}},
+		{"ssh://user@jump:2222", []string{"user@jump:2222"}},
+	}
+
+	for _, tt := range tests {
+		got := ParseProxyJump(tt.value)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParseProxyJump(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTokens(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"nc -X connect -x proxy:3128 %h %p", "nc -X connect -x proxy:3128 10.0.0.5 2222"},
+		{"wrapper --user %r --host %n", "wrapper --user deploy --host db"},
+		{"echo 100%% %x", "echo 100% %x"},
+		{"trailing %", "trailing %"},
+	}
+
+	for _, tt := range tests {
+		got := expandTokens(tt.value, "db", "10.0.0.5", "2222", "deploy")
+		if got != tt.want {
+			t.Errorf("expandTokens(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
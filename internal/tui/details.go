@@ -1,10 +1,15 @@
 package tui
 
 import (
+	"cmp"
+	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/JoshElias/gurren/internal/daemon"
 	"github.com/JoshElias/gurren/internal/tunnel"
 )
 
@@ -27,15 +32,7 @@ func (d *DetailsPanel) SetSize(w, h int) {
 
 // View renders the details panel for the given tunnel
 func (d DetailsPanel) View(item *TunnelItem) string {
-	// Content area dimensions (inside border)
-	contentWidth := d.width - 2 // account for left/right borders
-	if contentWidth < 0 {
-		contentWidth = 0
-	}
-	contentHeight := d.height - 2 // account for top/bottom borders
-	if contentHeight < 0 {
-		contentHeight = 0
-	}
+	contentWidth, contentHeight := d.contentSize()
 
 	var content string
 	if item == nil {
@@ -53,6 +50,47 @@ func (d DetailsPanel) View(item *TunnelItem) string {
 		Render(content)
 }
 
+// ViewGroup renders the details panel for a group header
+func (d DetailsPanel) ViewGroup(group *GroupItem) string {
+	_, contentHeight := d.contentSize()
+	return panelStyle.
+		Width(d.width).
+		Height(d.height).
+		Render(d.renderGroup(group, contentHeight))
+}
+
+// contentSize returns the content area dimensions (inside border)
+func (d DetailsPanel) contentSize() (width, height int) {
+	return max(d.width-2, 0), max(d.height-2, 0)
+}
+
+// renderGroup renders a group's aggregate status and its tunnels
+func (d DetailsPanel) renderGroup(group *GroupItem, height int) string {
+	var lines []string
+
+	lines = append(lines, d.renderRow(IconGroup, "Group", group.Name))
+	lines = append(lines, "")
+
+	status := fmt.Sprintf("%s (%d/%d connected)", group.Status(), group.Connected(), len(group.Tunnels))
+	lines = append(lines, d.renderRow(IconStatus, "Status", GroupStatusIcon(group.Status())+" "+status))
+	lines = append(lines, "")
+
+	lines = append(lines, d.renderRow(IconTunnel, "Tunnels", ""))
+	for _, t := range group.Tunnels {
+		lines = append(lines, "  "+StatusIcon(t.Status)+" "+labelStyle.Render(t.Name)+StatusText(t.Status))
+	}
+
+	content := strings.Join(lines, "\n")
+
+	// Pad to fill height
+	lineCount := strings.Count(content, "\n") + 1
+	if lineCount < height {
+		content += strings.Repeat("\n", height-lineCount)
+	}
+
+	return content
+}
+
 // renderEmpty renders the empty state for the details panel
 func (d DetailsPanel) renderEmpty(width, height int) string {
 	msg := mutedStyle.Render("No tunnel selected")
@@ -68,24 +106,54 @@ func (d DetailsPanel) renderDetails(item *TunnelItem, width, height int) string
 
 	// Name
 	lines = append(lines, d.renderRow(IconName, "Name", item.Name))
+	if item.Group != "" {
+		lines = append(lines, d.renderRow(IconGroup, "Group", item.Group))
+	}
+	if len(item.Tags) > 0 {
+		lines = append(lines, d.renderRow(IconTag, "Tags", strings.Join(item.Tags, ", ")))
+	}
 	lines = append(lines, "")
 
 	// Status with colored indicator
-	statusIcon := StatusIcon(
-		item.Status == tunnel.StateConnected,
-		item.Status == tunnel.StateConnecting,
-		item.Status == tunnel.StateError,
-	)
-	statusText := StatusText(
-		item.Status == tunnel.StateConnected,
-		item.Status == tunnel.StateConnecting,
-		item.Status == tunnel.StateError,
-	)
+	statusIcon := StatusIcon(item.Status)
+	statusText := StatusText(item.Status)
 	lines = append(lines, d.renderRow(IconStatus, "Status", statusIcon+" "+statusText))
 
+	// Uptime ticks along with the stats refresh
+	life := item.Lifecycle
+	if !life.ConnectedAt.IsZero() {
+		uptime := tunnel.FormatUptime(time.Since(life.ConnectedAt)) + ", since " + life.ConnectedAt.Format("15:04:05")
+		lines = append(lines, d.renderRow(IconUptime, "Uptime", uptime))
+	}
+	if life.Restarts > 0 {
+		restarts := fmt.Sprintf("%d", life.Restarts)
+		if life.Reconnects > 0 {
+			restarts += fmt.Sprintf(" (%d automatic)", life.Reconnects)
+		}
+		lines = append(lines, d.renderRow(IconReconnecting, "Restarts", restarts))
+	}
+	if life.LastDisconnectReason != "" && item.Error == "" {
+		lines = append(lines, d.renderRowValue("", "Last drop", mutedStyle.Render(life.LastDisconnectReason)))
+	}
+	if !life.LastErrorAt.IsZero() {
+		lines = append(lines, d.renderRowValue("", "Last error", mutedStyle.Render(life.LastErrorAt.Format(time.DateTime))))
+	}
+
+	// Reconnect progress
+	if item.Attempt > 0 {
+		retry := fmt.Sprintf("Attempt %d", item.Attempt)
+		if !item.NextRetry.IsZero() {
+			retry += ", next at " + item.NextRetry.Format("15:04:05")
+		}
+		lines = append(lines, d.renderRowValue("", "Retry", mutedStyle.Render(retry)))
+	}
+
 	// Error message if present
 	if item.Error != "" {
 		lines = append(lines, "")
+		if item.ErrorPhase != "" {
+			lines = append(lines, d.renderRowValue("", "Failed during", statusErrorStyle.Render(string(item.ErrorPhase))))
+		}
 		errorText := statusErrorStyle.Render(item.Error)
 		lines = append(lines, d.renderRowValue("", "Error", errorText))
 	}
@@ -97,6 +165,12 @@ func (d DetailsPanel) renderDetails(item *TunnelItem, width, height int) string
 		lines = append(lines, ephText)
 	}
 
+	// Config changed while the tunnel was running
+	if item.RestartPending {
+		lines = append(lines, "")
+		lines = append(lines, statusConnectingStyle.Render(IconPending+" Restart pending (config changed)"))
+	}
+
 	lines = append(lines, "")
 
 	// Connection details
@@ -107,12 +181,29 @@ func (d DetailsPanel) renderDetails(item *TunnelItem, width, height int) string
 	if port != "" && port != "22" {
 		lines = append(lines, d.renderRow(IconPort, "Port", port))
 	}
+	if len(item.Jumps) > 0 {
+		// Full chain from the first jump host to the tunnel host
+		route := append(slices.Clone(item.Jumps), item.Host)
+		lines = append(lines, d.renderRow(IconJump, "Route", strings.Join(route, " → ")))
+	}
+	if len(item.SharedWith) > 0 {
+		lines = append(lines, d.renderRow(IconShared, "Shared with", strings.Join(item.SharedWith, ", ")))
+	}
 
 	lines = append(lines, "")
 
 	// Tunnel endpoints
-	lines = append(lines, d.renderRow(IconLocal, "Local", item.Local))
-	lines = append(lines, d.renderRow(IconRemote, "Remote", item.Remote))
+	if len(item.Forwards) > 1 {
+		lines = append(lines, d.renderForwards(item.Forwards)...)
+	} else {
+		lines = append(lines, d.renderEndpoints(item)...)
+	}
+
+	// Traffic, once the tunnel has been used
+	if item.Stats.TotalConns > 0 {
+		lines = append(lines, "")
+		lines = append(lines, d.renderStats(item, width)...)
+	}
 
 	content := strings.Join(lines, "\n")
 
@@ -125,6 +216,107 @@ func (d DetailsPanel) renderDetails(item *TunnelItem, width, height int) string
 	return content
 }
 
+// renderEndpoints renders the addresses of a tunnel with a single forward
+func (d DetailsPanel) renderEndpoints(item *TunnelItem) []string {
+	switch item.Kind {
+	case tunnel.KindSocks:
+		return []string{d.renderRow(IconProxy, "Proxy", "socks5://"+item.listenAddr())}
+	case tunnel.KindRemote:
+		return []string{
+			d.renderRow(IconRemote, "Listen", item.listenAddr()),
+			d.renderRow(IconLocal, "Target", item.Local),
+		}
+	default:
+		return []string{
+			d.renderRow(IconLocal, "Local", item.listenAddr()),
+			d.renderRow(IconRemote, "Remote", item.Remote),
+		}
+	}
+}
+
+// renderForwards renders each forward of a tunnel with several, with
+// its status and why it failed to start
+func (d DetailsPanel) renderForwards(forwards []daemon.ForwardInfo) []string {
+	lines := []string{d.renderRow(IconForwards, "Forwards", fmt.Sprintf("%d", len(forwards)))}
+	for _, f := range forwards {
+		local := cmp.Or(f.ListenAddr, f.Local)
+		var endpoints string
+		switch tunnel.Kind(f.Type) {
+		case tunnel.KindSocks:
+			endpoints = "socks5://" + local
+		case tunnel.KindRemote:
+			endpoints = f.Local + " <- " + cmp.Or(f.ListenAddr, f.Remote)
+		default:
+			endpoints = local + " -> " + f.Remote
+		}
+
+		icon := mutedStyle.Render(IconDisconnected)
+		if f.Error != "" {
+			icon = statusErrorStyle.Render(IconError)
+		} else if f.ListenAddr != "" {
+			icon = statusConnectedStyle.Render(IconConnected)
+		}
+		lines = append(lines, "  "+icon+" "+labelStyle.Render(f.Name)+valueStyle.Render(endpoints))
+		if f.Error != "" {
+			lines = append(lines, "    "+statusErrorStyle.Render(f.Error))
+		}
+	}
+	return lines
+}
+
+// renderStats renders a tunnel's traffic counters and a sparkline of
+// its recent throughput
+func (d DetailsPanel) renderStats(item *TunnelItem, width int) []string {
+	s := item.Stats
+	lines := []string{
+		d.renderRow(IconTraffic, "Traffic", fmt.Sprintf("↓ %s  ↑ %s", tunnel.FormatBytes(s.BytesIn), tunnel.FormatBytes(s.BytesOut))),
+	}
+
+	conns := fmt.Sprintf("%d active, %d total", s.ActiveConns, s.TotalConns)
+	if s.FailedDials > 0 {
+		conns += ", " + statusErrorStyle.Render(fmt.Sprintf("%d failed", s.FailedDials))
+	}
+	lines = append(lines, d.renderRow(IconConnections, "Connections", conns))
+
+	if !s.LastActivity.IsZero() {
+		lines = append(lines, d.renderRow(IconActivity, "Last active", s.LastActivity.Format("15:04:05")))
+	}
+
+	if len(item.Throughput) > 0 {
+		rate := tunnel.FormatBytes(uint64(item.Throughput[len(item.Throughput)-1])) + "/s"
+		// Room left after the icon, label and rate
+		graphWidth := width - 2 - lipgloss.Width(labelStyle.Render("")) - len(rate) - 1
+		lines = append(lines, d.renderRowValue(IconThroughput, "Throughput",
+			statusConnectedStyle.Render(sparkline(item.Throughput, graphWidth))+" "+valueStyle.Render(rate)))
+	}
+	return lines
+}
+
+// sparkBlocks are the bar heights of a sparkline, lowest first
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws the most recent samples that fit in width as bars
+// scaled to the largest of them
+func sparkline(samples []float64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+
+	peak := slices.Max(samples)
+	var b strings.Builder
+	for _, v := range samples {
+		i := 0
+		if peak > 0 {
+			i = int(v / peak * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
 // renderRow renders a labeled row with icon
 func (d DetailsPanel) renderRow(icon, label, value string) string {
 	iconPart := mutedStyle.Render(icon)
@@ -4,11 +4,13 @@ import "github.com/charmbracelet/bubbles/key"
 
 // KeyMap defines the key bindings for the TUI
 type KeyMap struct {
-	Up     key.Binding
-	Down   key.Binding
-	Toggle key.Binding
-	Filter key.Binding
-	Quit   key.Binding
+	Up       key.Binding
+	Down     key.Binding
+	Toggle   key.Binding
+	Collapse key.Binding
+	Filter   key.Binding
+	Logs     key.Binding
+	Quit     key.Binding
 }
 
 // DefaultKeyMap returns the default key bindings
@@ -26,10 +28,18 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("enter"),
 			key.WithHelp("enter", "toggle"),
 		),
+		Collapse: key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("space", "fold group"),
+		),
 		Filter: key.NewBinding(
 			key.WithKeys("/"),
 			key.WithHelp("/", "filter"),
 		),
+		Logs: key.NewBinding(
+			key.WithKeys("l"),
+			key.WithHelp("l", "logs"),
+		),
 		Quit: key.NewBinding(
 			key.WithKeys("q", "ctrl+c"),
 			key.WithHelp("q", "quit"),
@@ -39,14 +49,14 @@ func DefaultKeyMap() KeyMap {
 
 // ShortHelp returns bindings shown in the mini help view
 func (k KeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Up, k.Toggle, k.Filter, k.Quit}
+	return []key.Binding{k.Up, k.Toggle, k.Collapse, k.Filter, k.Logs, k.Quit}
 }
 
 // FullHelp returns bindings for the expanded help view (not used currently)
 func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Up, k.Down},
-		{k.Toggle, k.Filter},
+		{k.Toggle, k.Collapse, k.Filter, k.Logs},
 		{k.Quit},
 	}
 }
@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/JoshElias/gurren/internal/daemon"
+	"github.com/JoshElias/gurren/internal/tunnel"
+)
+
+// logPanelEntries is how many entries the log panel keeps for the
+// shown tunnel
+const logPanelEntries = 200
+
+// LogPanel renders the bottom panel with the selected tunnel's log
+type LogPanel struct {
+	width   int
+	height  int
+	name    string // Tunnel whose log is shown
+	entries []daemon.LogEntry
+}
+
+// NewLogPanel creates a new log panel
+func NewLogPanel() LogPanel {
+	return LogPanel{}
+}
+
+// SetSize sets the panel dimensions, inside the border
+func (l *LogPanel) SetSize(w, h int) {
+	l.width = w
+	l.height = h
+}
+
+// Name returns the tunnel whose log is shown
+func (l LogPanel) Name() string {
+	return l.name
+}
+
+// SetEntries shows a tunnel's log
+func (l *LogPanel) SetEntries(name string, entries []daemon.LogEntry) {
+	l.name = name
+	l.entries = entries
+}
+
+// Append adds a new entry if it belongs to the shown tunnel
+func (l *LogPanel) Append(e daemon.LogEntry) {
+	if e.Name != l.name {
+		return
+	}
+	l.entries = append(l.entries, e)
+	if len(l.entries) > logPanelEntries {
+		l.entries = l.entries[len(l.entries)-logPanelEntries:]
+	}
+}
+
+// View renders the most recent entries that fit, newest at the bottom
+func (l LogPanel) View() string {
+	width, height := l.width, l.height
+
+	var lines []string
+	if l.name == "" {
+		lines = append(lines, mutedStyle.Render("No tunnel selected"))
+	} else if len(l.entries) == 0 {
+		lines = append(lines, mutedStyle.Render("No log entries for "+l.name))
+	}
+
+	entries := l.entries
+	if len(entries) > height {
+		entries = entries[len(entries)-height:]
+	}
+	for _, e := range entries {
+		line := mutedStyle.Render(e.Time.Local().Format("15:04:05")) + " " +
+			logLevelStyle(e.Level).Render(string(e.Event)) + " " +
+			normalStyle.Render(e.Message)
+		lines = append(lines, lipgloss.NewStyle().MaxWidth(width).Render(line))
+	}
+
+	content := strings.Join(lines, "\n")
+	if n := len(lines); n < height {
+		content += strings.Repeat("\n", height-n)
+	}
+
+	return panelStyle.
+		Width(l.width).
+		Height(l.height).
+		Render(content)
+}
+
+// logLevelStyle colors an entry's event by its level
+func logLevelStyle(level tunnel.Level) lipgloss.Style {
+	switch level {
+	case tunnel.LevelError:
+		return statusErrorStyle
+	case tunnel.LevelWarn:
+		return statusConnectingStyle
+	default:
+		return secondaryStyle
+	}
+}
@@ -0,0 +1,326 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/JoshElias/gurren/internal/daemon"
+)
+
+// promptKeyMap defines the key bindings inside a prompt modal
+type promptKeyMap struct {
+	Switch key.Binding
+	Submit key.Binding
+	Accept key.Binding
+	Reject key.Binding
+	Cancel key.Binding
+	Next   key.Binding
+	Prev   key.Binding
+}
+
+var promptKeys = promptKeyMap{
+	Switch: key.NewBinding(key.WithKeys("left", "right", "h", "l", "tab", "shift+tab")),
+	Submit: key.NewBinding(key.WithKeys("enter")),
+	Accept: key.NewBinding(key.WithKeys("y")),
+	Reject: key.NewBinding(key.WithKeys("n", "esc")),
+	Cancel: key.NewBinding(key.WithKeys("esc")),
+	Next:   key.NewBinding(key.WithKeys("tab", "down")),
+	Prev:   key.NewBinding(key.WithKeys("shift+tab", "up")),
+}
+
+// promptKind distinguishes the prompts the daemon can send
+type promptKind int
+
+const (
+	promptHostKey promptKind = iota
+	promptSecret
+	promptChallenge
+)
+
+// PromptModal is a dialog answering a prompt sent by the daemon
+type PromptModal struct {
+	ID   string
+	kind promptKind
+
+	// Host key prompts
+	hostKey daemon.HostKeyPromptParams
+	accept  bool // true when the accept button is focused
+
+	// Secret and challenge prompts
+	secret    daemon.SecretPromptParams
+	challenge daemon.ChallengePromptParams
+	inputs    []textinput.Model
+	focus     int // index of the focused input
+}
+
+// NewHostKeyPrompt creates a modal asking to trust an unknown host key
+func NewHostKeyPrompt(params daemon.HostKeyPromptParams) PromptModal {
+	return PromptModal{
+		ID:      params.ID,
+		kind:    promptHostKey,
+		hostKey: params,
+	}
+}
+
+// NewSecretPrompt creates a modal with a masked input for a password or passphrase
+func NewSecretPrompt(params daemon.SecretPromptParams) PromptModal {
+	return PromptModal{
+		ID:     params.ID,
+		kind:   promptSecret,
+		secret: params,
+		inputs: []textinput.Model{newPromptInput(false)},
+	}
+}
+
+// NewChallengePrompt creates a form with one input per keyboard-interactive question
+func NewChallengePrompt(params daemon.ChallengePromptParams) PromptModal {
+	inputs := make([]textinput.Model, len(params.Questions))
+	for i, q := range params.Questions {
+		inputs[i] = newPromptInput(q.Echo)
+		if i > 0 {
+			inputs[i].Blur()
+		}
+	}
+	return PromptModal{
+		ID:        params.ID,
+		kind:      promptChallenge,
+		challenge: params,
+		inputs:    inputs,
+	}
+}
+
+// newPromptInput creates a focused text input, masked unless echo is set
+func newPromptInput(echo bool) textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.PromptStyle = lipgloss.NewStyle().Foreground(colorBlue)
+	ti.TextStyle = lipgloss.NewStyle().Foreground(colorFg)
+	ti.Cursor.Style = lipgloss.NewStyle().Foreground(colorBlue)
+	ti.Width = 40
+	if !echo {
+		ti.EchoMode = textinput.EchoPassword
+		ti.EchoCharacter = '•'
+	}
+	ti.Focus()
+	return ti
+}
+
+// promptAnsweredMsg is sent once the user has answered the current prompt
+type promptAnsweredMsg struct {
+	id string
+}
+
+// Update handles key presses. The returned command sends the answer
+// to the daemon once the user has decided.
+func (p *PromptModal) Update(msg tea.KeyMsg, client *daemon.Client) tea.Cmd {
+	if p.kind != promptHostKey {
+		return p.updateInputs(msg, client)
+	}
+
+	switch {
+	case key.Matches(msg, promptKeys.Switch):
+		p.accept = !p.accept
+		return nil
+	case key.Matches(msg, promptKeys.Accept):
+		return p.answerHostKey(client, true)
+	case key.Matches(msg, promptKeys.Reject):
+		return p.answerHostKey(client, false)
+	case key.Matches(msg, promptKeys.Submit):
+		return p.answerHostKey(client, p.accept)
+	}
+	return nil
+}
+
+// updateInputs handles key presses for secret and challenge forms.
+// Enter moves to the next field and submits from the last one.
+func (p *PromptModal) updateInputs(msg tea.KeyMsg, client *daemon.Client) tea.Cmd {
+	switch {
+	case key.Matches(msg, promptKeys.Cancel):
+		return p.answer(client, true)
+	case key.Matches(msg, promptKeys.Submit):
+		if p.focus == len(p.inputs)-1 {
+			return p.answer(client, false)
+		}
+		p.setFocus(p.focus + 1)
+		return nil
+	case key.Matches(msg, promptKeys.Next):
+		p.setFocus(p.focus + 1)
+		return nil
+	case key.Matches(msg, promptKeys.Prev):
+		p.setFocus(p.focus - 1)
+		return nil
+	}
+
+	var cmd tea.Cmd
+	p.inputs[p.focus], cmd = p.inputs[p.focus].Update(msg)
+	return cmd
+}
+
+// setFocus moves the cursor to input i, wrapping around
+func (p *PromptModal) setFocus(i int) {
+	if len(p.inputs) == 0 {
+		return
+	}
+	p.inputs[p.focus].Blur()
+	p.focus = (i + len(p.inputs)) % len(p.inputs)
+	p.inputs[p.focus].Focus()
+}
+
+// answer sends the form's values (or a cancellation) to the daemon
+func (p *PromptModal) answer(client *daemon.Client, cancel bool) tea.Cmd {
+	if p.kind == promptSecret {
+		secret := ""
+		if !cancel {
+			secret = p.inputs[0].Value()
+		}
+		return p.answerSecret(client, secret, cancel)
+	}
+
+	var answers []string
+	if !cancel {
+		for _, in := range p.inputs {
+			answers = append(answers, in.Value())
+		}
+	}
+	return p.answerChallenge(client, answers, cancel)
+}
+
+// answerHostKey sends the host key decision to the daemon
+func (p *PromptModal) answerHostKey(client *daemon.Client, accept bool) tea.Cmd {
+	id := p.ID
+	return func() tea.Msg {
+		if err := client.AnswerHostKey(id, accept); err != nil {
+			return errorMsg{err}
+		}
+		return promptAnsweredMsg{id}
+	}
+}
+
+// answerSecret sends the entered secret (or a cancellation) to the daemon
+func (p *PromptModal) answerSecret(client *daemon.Client, secret string, cancel bool) tea.Cmd {
+	id := p.ID
+	return func() tea.Msg {
+		if err := client.AnswerSecret(id, secret, cancel); err != nil {
+			return errorMsg{err}
+		}
+		return promptAnsweredMsg{id}
+	}
+}
+
+// answerChallenge sends the keyboard-interactive answers to the daemon
+func (p *PromptModal) answerChallenge(client *daemon.Client, answers []string, cancel bool) tea.Cmd {
+	id := p.ID
+	return func() tea.Msg {
+		if err := client.AnswerChallenge(id, answers, cancel); err != nil {
+			return errorMsg{err}
+		}
+		return promptAnsweredMsg{id}
+	}
+}
+
+// View renders the modal
+func (p PromptModal) View() string {
+	switch p.kind {
+	case promptSecret:
+		return p.viewSecret()
+	case promptChallenge:
+		return p.viewChallenge()
+	}
+
+	var lines []string
+
+	lines = append(lines, modalTitleStyle.Render(IconWarning+" Unknown host key"))
+	lines = append(lines, "")
+	lines = append(lines, normalStyle.Render("The authenticity of "+p.hostKey.Host+" can't be established."))
+	lines = append(lines, "")
+	lines = append(lines, p.renderRow("Tunnel", p.hostKey.Name))
+	lines = append(lines, p.renderRow("Key type", p.hostKey.KeyType))
+	lines = append(lines, p.renderRow("Fingerprint", p.hostKey.Fingerprint))
+	lines = append(lines, "")
+	lines = append(lines, normalStyle.Render("Trust this key and continue connecting?"))
+	lines = append(lines, "")
+	lines = append(lines, p.renderButtons())
+
+	return modalStyle.Render(strings.Join(lines, "\n"))
+}
+
+// viewSecret renders the masked input modal
+func (p PromptModal) viewSecret() string {
+	var lines []string
+
+	lines = append(lines, modalTitleStyle.Render(IconLock+" Authentication required"))
+	lines = append(lines, "")
+	lines = append(lines, p.renderRow("Tunnel", p.secret.Name))
+	lines = append(lines, "")
+	lines = append(lines, normalStyle.Render(strings.TrimSpace(p.secret.Prompt)))
+	lines = append(lines, p.inputs[0].View())
+	lines = append(lines, "")
+	lines = append(lines, p.renderHelp("enter", "submit", "esc", "cancel"))
+
+	return modalStyle.Render(strings.Join(lines, "\n"))
+}
+
+// viewChallenge renders the keyboard-interactive form
+func (p PromptModal) viewChallenge() string {
+	var lines []string
+
+	title := p.challenge.Title
+	if title == "" {
+		title = "Authentication required"
+	}
+	lines = append(lines, modalTitleStyle.Render(IconLock+" "+title))
+	lines = append(lines, "")
+	lines = append(lines, p.renderRow("Tunnel", p.challenge.Name))
+	if instruction := strings.TrimSpace(p.challenge.Instruction); instruction != "" {
+		lines = append(lines, "")
+		lines = append(lines, normalStyle.Render(instruction))
+	}
+
+	for i, q := range p.challenge.Questions {
+		lines = append(lines, "")
+		lines = append(lines, normalStyle.Render(strings.TrimSpace(q.Prompt)))
+		lines = append(lines, p.inputs[i].View())
+	}
+
+	lines = append(lines, "")
+	if len(p.inputs) > 1 {
+		lines = append(lines, p.renderHelp("tab", "next field", "enter", "submit", "esc", "cancel"))
+	} else {
+		lines = append(lines, p.renderHelp("enter", "submit", "esc", "cancel"))
+	}
+
+	return modalStyle.Render(strings.Join(lines, "\n"))
+}
+
+// renderRow renders a labeled row inside the modal
+func (p PromptModal) renderRow(label, value string) string {
+	return labelStyle.Render(label) + valueStyle.Render(value)
+}
+
+// renderHelp renders key/description pairs like the status bar
+func (p PromptModal) renderHelp(pairs ...string) string {
+	var parts []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, helpKeyStyle.Render(pairs[i])+" "+helpDescStyle.Render(pairs[i+1]))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// renderButtons renders the accept/reject buttons with the focused one highlighted
+func (p PromptModal) renderButtons() string {
+	accept, reject := buttonStyle, buttonStyle
+	if p.accept {
+		accept = activeButtonStyle
+	} else {
+		reject = activeButtonStyle
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top,
+		accept.Render("Accept (y)"),
+		"  ",
+		reject.Render("Reject (n)"),
+	)
+}
@@ -1,6 +1,10 @@
 package tui
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/JoshElias/gurren/internal/tunnel"
+)
 
 // OneDark color palette
 var (
@@ -24,6 +28,7 @@ const (
 	IconDisconnected = "\uf10c" //  (circle outline)
 	IconConnecting   = "\uf110" //  (spinner)
 	IconError        = "\uf00d" //  (x mark)
+	IconReconnecting = "\uf021" //  (refresh)
 	IconTunnel       = "󰛳"      // Panel title - network
 	IconDetails      = ""       // Panel title - info
 	IconUser         = ""       // User field
@@ -31,9 +36,26 @@ const (
 	IconPort         = "󰙜"      // Port field
 	IconLocal        = "󰌘"      // Local field
 	IconRemote       = "󰒍"      // Remote field
+	IconProxy        = "󰖟"      // SOCKS proxy field
+	IconJump         = "󰁔"      // Jump host chain
+	IconShared       = "󰌷"      // Shared connection
+	IconForwards     = "󰕒"      // Forwards list
 	IconStatus       = ""       // Status field
 	IconEphemeral    = ""       // Ephemeral indicator
 	IconName         = ""       // Name field
+	IconWarning      = "\uf071" //  (warning triangle)
+	IconLock         = "\uf023" //  (lock)
+	IconPending      = "\uf017" //  (clock) - restart pending
+	IconPartial      = "\uf042" //  (half circle) - group partly up
+	IconExpanded     = "\uf078" //  (chevron down) - open group
+	IconCollapsed    = "\uf054" //  (chevron right) - collapsed group
+	IconGroup        = "󰉋"      // Group field
+	IconTag          = "\uf02b" //  (tag)
+	IconTraffic      = "\uf0ec" //  (exchange) - bytes in and out
+	IconConnections  = "\uf0c1" //  (link) - forwarded connections
+	IconActivity     = "\uf1da" //  (history) - last activity
+	IconThroughput   = "\uf201" //  (line chart) - throughput sparkline
+	IconUptime       = "\uf253" //  (hourglass) - uptime
 )
 
 // Panel styles
@@ -153,16 +175,40 @@ var (
 				Foreground(colorCyan)
 )
 
+// Modal styles
+var (
+	modalStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(colorOrange).
+			Padding(1, 2)
+
+	modalTitleStyle = lipgloss.NewStyle().
+			Foreground(colorOrange).
+			Bold(true)
+
+	buttonStyle = lipgloss.NewStyle().
+			Foreground(colorGrey).
+			Padding(0, 2)
+
+	activeButtonStyle = lipgloss.NewStyle().
+				Foreground(colorBg).
+				Background(colorBlue).
+				Bold(true).
+				Padding(0, 2)
+)
+
 // Helper functions
 
 // StatusIcon returns the appropriate icon for a tunnel state
-func StatusIcon(connected, connecting, hasError bool) string {
+func StatusIcon(state tunnel.State) string {
 	switch {
-	case hasError:
+	case state.IsError():
 		return statusErrorStyle.Render(IconError)
-	case connecting:
+	case state == tunnel.StateConnecting:
 		return statusConnectingStyle.Render(IconConnecting)
-	case connected:
+	case state == tunnel.StateReconnecting:
+		return statusConnectingStyle.Render(IconReconnecting)
+	case state == tunnel.StateConnected:
 		return statusConnectedStyle.Render(IconConnected)
 	default:
 		return statusDisconnectedStyle.Render(IconDisconnected)
@@ -170,15 +216,31 @@ func StatusIcon(connected, connecting, hasError bool) string {
 }
 
 // StatusText returns styled status text
-func StatusText(connected, connecting, hasError bool) string {
-	switch {
-	case hasError:
+func StatusText(state tunnel.State) string {
+	switch state {
+	case tunnel.StateHostKeyMismatch:
+		return statusErrorStyle.Render("Host key mismatch")
+	case tunnel.StateError:
 		return statusErrorStyle.Render("Error")
-	case connecting:
+	case tunnel.StateConnecting:
 		return statusConnectingStyle.Render("Connecting")
-	case connected:
+	case tunnel.StateReconnecting:
+		return statusConnectingStyle.Render("Reconnecting")
+	case tunnel.StateConnected:
 		return statusConnectedStyle.Render("Connected")
 	default:
 		return statusDisconnectedStyle.Render("Disconnected")
 	}
 }
+
+// GroupStatusIcon returns the icon for a group's aggregate status
+func GroupStatusIcon(status GroupStatus) string {
+	switch status {
+	case GroupAllUp:
+		return statusConnectedStyle.Render(IconConnected)
+	case GroupPartial:
+		return statusConnectingStyle.Render(IconPartial)
+	default:
+		return statusDisconnectedStyle.Render(IconDisconnected)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
@@ -21,6 +22,18 @@ type Model struct {
 	listPanel    TunnelListPanel
 	detailsPanel DetailsPanel
 	statusBar    StatusBar
+	logPanel     LogPanel
+
+	// Prompts from the daemon awaiting an answer; the first one is shown
+	prompts []PromptModal
+
+	// Throughput history per tunnel, from polling tunnel.stats
+	throughput map[string][]float64
+	lastStats  statsLoadedMsg
+
+	// Log panel for the selected tunnel, toggled with l
+	showLogs       bool
+	logsSubscribed bool
 
 	// State
 	keys   KeyMap
@@ -39,11 +52,43 @@ type tunnelsLoadedMsg struct {
 
 // tunnelStatusChangedMsg is sent when a tunnel status changes
 type tunnelStatusChangedMsg struct {
-	name   string
-	status tunnel.State
-	err    string
+	name       string
+	status     tunnel.State
+	err        string
+	attempt    int
+	nextRetry  time.Time
+	phase      tunnel.Phase
+	listenAddr string
+	connection string
+	forwards   []daemon.ForwardInfo
+	removed    bool
+	lifecycle  daemon.TunnelLifecycle
+}
+
+// statsTickMsg is sent when it's time to poll the tunnel counters
+type statsTickMsg struct{}
+
+// statsLoadedMsg is sent with the tunnel counters from the daemon
+type statsLoadedMsg struct {
+	stats map[string]daemon.TunnelStats
+	at    time.Time
+}
+
+// statsInterval is how often the tunnel counters are polled
+const statsInterval = time.Second
+
+// throughputSamples is how many throughput samples are kept per tunnel
+const throughputSamples = 60
+
+// logsLoadedMsg is sent with a tunnel's recent log entries
+type logsLoadedMsg struct {
+	name    string
+	entries []daemon.LogEntry
 }
 
+// logPanelHeight is the height of the log panel, borders included
+const logPanelHeight = 10
+
 // errorMsg is sent when an error occurs
 type errorMsg struct {
 	err error
@@ -58,9 +103,11 @@ func New(client *daemon.Client) Model {
 	return Model{
 		listPanel:    NewTunnelListPanel(),
 		detailsPanel: NewDetailsPanel(),
+		logPanel:     NewLogPanel(),
 		statusBar:    NewStatusBar(keys),
 		keys:         keys,
 		client:       client,
+		throughput:   make(map[string][]float64),
 	}
 }
 
@@ -69,9 +116,64 @@ func (m Model) Init() tea.Cmd {
 	return tea.Batch(
 		m.loadTunnels(),
 		m.listenForNotifications(),
+		m.loadStats(),
 	)
 }
 
+// tickStats schedules the next poll of the tunnel counters
+func tickStats() tea.Cmd {
+	return tea.Tick(statsInterval, func(time.Time) tea.Msg {
+		return statsTickMsg{}
+	})
+}
+
+// loadStats polls the tunnel counters. Failures are left to the next poll.
+func (m Model) loadStats() tea.Cmd {
+	return func() tea.Msg {
+		result, err := m.client.TunnelStats("")
+		if err != nil {
+			return statsLoadedMsg{at: time.Now()}
+		}
+		return statsLoadedMsg{stats: result.Tunnels, at: time.Now()}
+	}
+}
+
+// updateStats records new counters, adding a throughput sample for each
+// tunnel from the bytes moved since the last poll
+func (m *Model) updateStats(msg statsLoadedMsg) {
+	if msg.stats == nil {
+		return
+	}
+
+	elapsed := msg.at.Sub(m.lastStats.at).Seconds()
+	for name, s := range msg.stats {
+		prev, ok := m.lastStats.stats[name]
+		if !ok || elapsed <= 0 {
+			continue
+		}
+		moved := (s.BytesIn + s.BytesOut) - (prev.BytesIn + prev.BytesOut)
+		samples := append(m.throughput[name], float64(moved)/elapsed)
+		if len(samples) > throughputSamples {
+			samples = samples[len(samples)-throughputSamples:]
+		}
+		m.throughput[name] = samples
+	}
+	m.lastStats = msg
+
+	// Rebuilding the list is only needed when a counter moved
+	items := m.listPanel.Items()
+	changed := false
+	for i := range items {
+		if s, ok := msg.stats[items[i].Name]; ok && s != items[i].Stats {
+			items[i].Stats = s
+			changed = true
+		}
+	}
+	if changed {
+		m.listPanel.SetItems(items)
+	}
+}
+
 // loadTunnels loads tunnels from the daemon
 func (m Model) loadTunnels() tea.Cmd {
 	return func() tea.Msg {
@@ -83,13 +185,27 @@ func (m Model) loadTunnels() tea.Cmd {
 		tunnels := make([]TunnelItem, len(result.Tunnels))
 		for i, t := range result.Tunnels {
 			tunnels[i] = TunnelItem{
-				Name:      t.Name,
-				Host:      t.Config.Host,
-				Status:    t.Status,
-				Error:     t.Error,
-				Ephemeral: t.Ephemeral,
-				Local:     t.Config.Local,
-				Remote:    t.Config.Remote,
+				Name:       t.Name,
+				Host:       t.Config.Host,
+				Group:      t.Config.Group,
+				Tags:       t.Config.Tags,
+				Status:     t.Status,
+				Error:      t.Error,
+				Ephemeral:  t.Ephemeral,
+				Kind:       tunnel.Kind(t.Config.Type),
+				Local:      t.Config.Local,
+				Remote:     t.Config.Remote,
+				Attempt:    t.Attempt,
+				NextRetry:  t.NextRetry,
+				ErrorPhase: t.ErrorPhase,
+				ListenAddr: t.ListenAddr,
+				Connection: t.Connection,
+				Forwards:   t.Forwards,
+				Jumps:      t.Jumps,
+
+				RestartPending: t.RestartPending,
+				Stats:          t.Stats,
+				Lifecycle:      t.TunnelLifecycle,
 			}
 		}
 
@@ -120,6 +236,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		// An open prompt takes all keys until it is answered
+		if len(m.prompts) > 0 {
+			if msg.String() == "ctrl+c" {
+				return m, tea.Quit
+			}
+			cmd := m.prompts[0].Update(msg, m.client)
+			return m, cmd
+		}
+
 		// If list is filtering, let it handle all keys
 		if m.listPanel.Filtering() {
 			cmd := m.listPanel.Update(msg)
@@ -132,7 +257,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case key.Matches(msg, m.keys.Up), key.Matches(msg, m.keys.Down):
 			cmd := m.listPanel.Update(msg)
-			return m, cmd
+			return m, tea.Batch(cmd, m.syncLogs())
 
 		case key.Matches(msg, m.keys.Filter):
 			// Start filtering
@@ -140,26 +265,64 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, cmd
 
 		case key.Matches(msg, m.keys.Toggle):
+			if group := m.listPanel.SelectedGroup(); group != nil {
+				return m, m.toggleGroup(*group)
+			}
 			if selected := m.listPanel.SelectedItem(); selected != nil {
 				return m, m.toggleTunnel(selected.Name)
 			}
 			return m, nil
+
+		case key.Matches(msg, m.keys.Collapse):
+			m.listPanel.ToggleCollapsed()
+			return m, nil
+
+		case key.Matches(msg, m.keys.Logs):
+			m.showLogs = !m.showLogs
+			m.updateLayout()
+			return m, m.syncLogs()
 		}
 
 	case tunnelsLoadedMsg:
 		m.listPanel.SetItems(msg.tunnels)
+		return m, m.syncLogs()
+
+	case logsLoadedMsg:
+		// Ignore logs for a tunnel that's no longer selected
+		if msg.name == m.logPanel.Name() {
+			m.logPanel.SetEntries(msg.name, msg.entries)
+		}
 		return m, nil
 
+	case statsTickMsg:
+		return m, m.loadStats()
+
+	case statsLoadedMsg:
+		m.updateStats(msg)
+		return m, tickStats()
+
 	case tunnelStatusChangedMsg:
 		// Update the tunnel in the list
 		items := m.listPanel.Items()
 		for i := range items {
 			if items[i].Name == msg.name {
+				// A pending config change was applied, or the tunnel removed
+				if msg.removed || (items[i].RestartPending && !msg.status.IsActive()) {
+					cmds = append(cmds, m.loadTunnels())
+				}
+
 				items[i].Status = msg.status
 				items[i].Error = msg.err
+				items[i].Attempt = msg.attempt
+				items[i].NextRetry = msg.nextRetry
+				items[i].ErrorPhase = msg.phase
+				items[i].ListenAddr = msg.listenAddr
+				items[i].Connection = msg.connection
+				items[i].Forwards = msg.forwards
+				items[i].Lifecycle = msg.lifecycle
 
 				// Show toast on error
-				if msg.status == tunnel.StateError && msg.err != "" {
+				if msg.status.IsError() && msg.err != "" {
 					m.statusBar.SetToast(msg.err, ToastError)
 					cmds = append(cmds, HideToastCmd())
 				}
@@ -170,21 +333,77 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tea.Batch(cmds...)
 
 	case notificationMsg:
-		// Parse the notification and convert to status change
-		if msg.Method == daemon.MethodStatusChanged {
+		listenCmd := m.listenForNotifications()
+
+		switch msg.Method {
+		case daemon.MethodStatusChanged:
+			// Parse the notification and convert to status change
 			var params daemon.StatusChangedParams
 			if err := json.Unmarshal(msg.Params, &params); err == nil {
 				// Update and continue listening
-				listenCmd := m.listenForNotifications()
 				newModel, updateCmd := m.Update(tunnelStatusChangedMsg{
-					name:   params.Name,
-					status: params.Status,
-					err:    params.Error,
+					name:       params.Name,
+					status:     params.Status,
+					err:        params.Error,
+					attempt:    params.Attempt,
+					nextRetry:  params.NextRetry,
+					phase:      params.ErrorPhase,
+					listenAddr: params.ListenAddr,
+					connection: params.Connection,
+					forwards:   params.Forwards,
+					removed:    params.Removed,
+					lifecycle:  params.TunnelLifecycle,
 				})
 				return newModel, tea.Batch(listenCmd, updateCmd)
 			}
+
+		case daemon.MethodHostKeyPrompt:
+			var params daemon.HostKeyPromptParams
+			if err := json.Unmarshal(msg.Params, &params); err == nil {
+				m.prompts = append(m.prompts, NewHostKeyPrompt(params))
+			}
+
+		case daemon.MethodSecretPrompt:
+			var params daemon.SecretPromptParams
+			if err := json.Unmarshal(msg.Params, &params); err == nil {
+				m.prompts = append(m.prompts, NewSecretPrompt(params))
+			}
+
+		case daemon.MethodChallengePrompt:
+			var params daemon.ChallengePromptParams
+			if err := json.Unmarshal(msg.Params, &params); err == nil {
+				m.prompts = append(m.prompts, NewChallengePrompt(params))
+			}
+
+		case daemon.MethodConfigReloaded:
+			var params daemon.ConfigReloadedParams
+			if err := json.Unmarshal(msg.Params, &params); err == nil {
+				if params.Error != "" {
+					m.statusBar.SetToast(params.Error, ToastError)
+				} else {
+					m.statusBar.SetToast("Config reloaded", ToastInfo)
+				}
+				return m, tea.Batch(listenCmd, m.loadTunnels(), HideToastCmd())
+			}
+
+		case daemon.MethodTunnelLog:
+			var entry daemon.LogEntry
+			if err := json.Unmarshal(msg.Params, &entry); err == nil {
+				m.logPanel.Append(entry)
+			}
+
+		case daemon.MethodPromptResolved:
+			// Answered elsewhere or timed out
+			var params daemon.PromptResolvedParams
+			if err := json.Unmarshal(msg.Params, &params); err == nil {
+				m.removePrompt(params.ID)
+			}
 		}
-		return m, m.listenForNotifications()
+		return m, listenCmd
+
+	case promptAnsweredMsg:
+		m.removePrompt(msg.id)
+		return m, nil
 
 	case errorMsg:
 		m.statusBar.SetToast(msg.err.Error(), ToastError)
@@ -198,12 +417,69 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// syncLogs loads the selected tunnel's log if the log panel is open
+// and showing another tunnel, subscribing to new entries the first time
+func (m *Model) syncLogs() tea.Cmd {
+	if !m.showLogs {
+		return nil
+	}
+
+	var name string
+	if selected := m.listPanel.SelectedItem(); selected != nil && m.listPanel.SelectedGroup() == nil {
+		name = selected.Name
+	}
+	if name == m.logPanel.Name() {
+		return nil
+	}
+	m.logPanel.SetEntries(name, nil)
+
+	var cmds []tea.Cmd
+	if !m.logsSubscribed {
+		m.logsSubscribed = true
+		client := m.client
+		cmds = append(cmds, func() tea.Msg {
+			if err := client.SubscribeLogs(""); err != nil {
+				return errorMsg{err}
+			}
+			return nil
+		})
+	}
+	if name != "" {
+		client := m.client
+		cmds = append(cmds, func() tea.Msg {
+			result, err := client.TunnelLogs(name, logPanelEntries)
+			if err != nil {
+				return errorMsg{err}
+			}
+			return logsLoadedMsg{name: name, entries: result.Entries}
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+// removePrompt drops a prompt from the queue
+func (m *Model) removePrompt(id string) {
+	for i := range m.prompts {
+		if m.prompts[i].ID == id {
+			m.prompts = append(m.prompts[:i], m.prompts[i+1:]...)
+			return
+		}
+	}
+}
+
 // updateLayout recalculates component sizes based on terminal dimensions
 func (m *Model) updateLayout() {
 	// Reserve height for status bar (1 line + padding)
 	statusBarHeight := 1
 	contentHeight := m.height - statusBarHeight
 
+	// The log panel takes the bottom rows when open
+	if m.showLogs {
+		logHeight := min(logPanelHeight, contentHeight/2)
+		m.logPanel.SetSize(max(m.width-2, 0), max(logHeight-2, 0))
+		contentHeight -= logHeight
+	}
+
 	// Calculate panel widths
 	// List panel: 33% of width, min 25, max 50
 	listWidth := m.width / 3
@@ -258,12 +534,37 @@ func (m Model) toggleTunnel(name string) tea.Cmd {
 	}
 }
 
+// toggleGroup stops every tunnel in the group if all of them are up,
+// otherwise starts the ones that aren't
+func (m Model) toggleGroup(group GroupItem) tea.Cmd {
+	return func() tea.Msg {
+		if group.AllActive() {
+			if _, err := m.client.TunnelStopGroup(group.Name, ""); err != nil {
+				return errorMsg{err}
+			}
+			return nil
+		}
+
+		// Failures are reported by status notifications
+		if _, err := m.client.TunnelStartGroup(group.Name, ""); err != nil {
+			return errorMsg{err}
+		}
+		return nil
+	}
+}
+
 // View renders the TUI
 func (m Model) View() string {
 	if m.width == 0 || m.height == 0 {
 		return ""
 	}
 
+	// A pending prompt is shown on top of everything else
+	if len(m.prompts) > 0 {
+		modal := lipgloss.Place(m.width, m.height-1, lipgloss.Center, lipgloss.Center, m.prompts[0].View())
+		return lipgloss.JoinVertical(lipgloss.Left, modal, m.statusBar.View())
+	}
+
 	// Check for empty state
 	if len(m.listPanel.Items()) == 0 && !m.listPanel.Filtering() {
 		return m.renderEmptyState()
@@ -271,10 +572,23 @@ func (m Model) View() string {
 
 	// Render panels
 	listView := m.listPanel.View()
-	detailsView := m.detailsPanel.View(m.listPanel.SelectedItem())
+	var detailsView string
+	if group := m.listPanel.SelectedGroup(); group != nil {
+		detailsView = m.detailsPanel.ViewGroup(group)
+	} else {
+		selected := m.listPanel.SelectedItem()
+		if selected != nil {
+			selected.SharedWith = m.listPanel.SharedWith(*selected)
+			selected.Throughput = m.throughput[selected.Name]
+		}
+		detailsView = m.detailsPanel.View(selected)
+	}
 
 	// Join panels horizontally
 	panels := lipgloss.JoinHorizontal(lipgloss.Top, listView, detailsView)
+	if m.showLogs {
+		panels = lipgloss.JoinVertical(lipgloss.Left, panels, m.logPanel.View())
+	}
 
 	// Add status bar
 	statusBar := m.statusBar.View()
@@ -331,8 +645,9 @@ local = "localhost:5432"`
 
 // Run starts the TUI
 func Run(client *daemon.Client) error {
-	// Subscribe to notifications
-	if err := client.Subscribe(); err != nil {
+	// Subscribe to notifications, answering prompts for tunnels started
+	// without a client (autostart, restore)
+	if err := client.Subscribe(true); err != nil {
 		return fmt.Errorf("failed to subscribe to notifications: %w", err)
 	}
 
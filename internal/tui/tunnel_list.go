@@ -1,26 +1,45 @@
 package tui
 
 import (
+	"cmp"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/JoshElias/gurren/internal/daemon"
 	"github.com/JoshElias/gurren/internal/tunnel"
 )
 
 // TunnelItem represents a tunnel in the list
 type TunnelItem struct {
-	Name      string
-	Host      string
-	Status    tunnel.State
-	Error     string
-	Ephemeral bool
-	Local     string
-	Remote    string
+	Name           string
+	Host           string
+	Group          string
+	Tags           []string
+	Status         tunnel.State
+	Error          string
+	Ephemeral      bool
+	Kind           tunnel.Kind
+	Local          string
+	Remote         string
+	Attempt        int                    // Reconnect attempt number
+	NextRetry      time.Time              // When the next reconnect attempt is due
+	ErrorPhase     tunnel.Phase           // Where connecting failed, if it did
+	ListenAddr     string                 // Bound address once listening
+	Connection     string                 // SSH connection in use while connected
+	SharedWith     []string               // Other tunnels on the same connection (set for the details panel)
+	Forwards       []daemon.ForwardInfo   // Each forward, when the tunnel has several
+	Jumps          []string               // Jump hosts the tunnel connects through
+	RestartPending bool                   // Config changed while active, applies once stopped
+	Stats          daemon.TunnelStats     // Traffic and connection counters
+	Lifecycle      daemon.TunnelLifecycle // Uptime, restarts and the last disconnect
+	Throughput     []float64              // Recent bytes per second, oldest first (set for the details panel)
 }
 
 // FilterValue implements list.Item for filtering
@@ -35,9 +54,84 @@ func (t TunnelItem) Title() string {
 
 // Description implements list.DefaultItem (not used with custom delegate)
 func (t TunnelItem) Description() string {
+	if len(t.Forwards) > 1 {
+		return fmt.Sprintf("%d forwards", len(t.Forwards))
+	}
+	switch t.Kind {
+	case tunnel.KindSocks:
+		return fmt.Sprintf("socks5://%s", t.Local)
+	case tunnel.KindRemote:
+		return fmt.Sprintf("%s <- %s", t.Local, t.listenAddr())
+	}
 	return fmt.Sprintf("%s -> %s", t.Local, t.Remote)
 }
 
+// listenAddr returns the bound address if known, otherwise the configured one.
+// For remote tunnels this is where the SSH server listens.
+func (t TunnelItem) listenAddr() string {
+	if t.ListenAddr != "" {
+		return t.ListenAddr
+	}
+	if t.Kind == tunnel.KindRemote {
+		return t.Remote
+	}
+	return t.Local
+}
+
+// GroupStatus is the aggregate status of a group's tunnels
+type GroupStatus string
+
+const (
+	GroupAllUp   GroupStatus = "all up"
+	GroupPartial GroupStatus = "partial"
+	GroupDown    GroupStatus = "down"
+)
+
+// GroupItem is a collapsible header for the tunnels in a group
+type GroupItem struct {
+	Name      string
+	Tunnels   []TunnelItem
+	Collapsed bool
+}
+
+// FilterValue implements list.Item for filtering
+func (g GroupItem) FilterValue() string {
+	return g.Name
+}
+
+// Connected returns how many of the group's tunnels are connected
+func (g GroupItem) Connected() int {
+	n := 0
+	for _, t := range g.Tunnels {
+		if t.Status == tunnel.StateConnected {
+			n++
+		}
+	}
+	return n
+}
+
+// Status aggregates the status of the group's tunnels
+func (g GroupItem) Status() GroupStatus {
+	switch g.Connected() {
+	case len(g.Tunnels):
+		return GroupAllUp
+	case 0:
+		return GroupDown
+	default:
+		return GroupPartial
+	}
+}
+
+// AllActive reports whether every tunnel in the group is up or coming up
+func (g GroupItem) AllActive() bool {
+	for _, t := range g.Tunnels {
+		if !t.Status.IsActive() {
+			return false
+		}
+	}
+	return true
+}
+
 // TunnelDelegate is a custom item delegate for rendering tunnel items
 type TunnelDelegate struct {
 	ShowEphemeral bool
@@ -65,22 +159,23 @@ func (d TunnelDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd {
 	return nil
 }
 
-// Render renders a single tunnel item
+// Render renders a single tunnel item or group header
 func (d TunnelDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	// Check if this item is selected
+	isSelected := index == m.Index()
+
+	if g, ok := item.(GroupItem); ok {
+		d.renderGroup(w, g, isSelected)
+		return
+	}
+
 	t, ok := item.(TunnelItem)
 	if !ok {
 		return
 	}
 
-	// Check if this item is selected
-	isSelected := index == m.Index()
-
 	// Status indicator
-	statusIcon := StatusIcon(
-		t.Status == tunnel.StateConnected,
-		t.Status == tunnel.StateConnecting,
-		t.Status == tunnel.StateError,
-	)
+	statusIcon := StatusIcon(t.Status)
 
 	// Build the line
 	var line strings.Builder
@@ -92,6 +187,11 @@ func (d TunnelDelegate) Render(w io.Writer, m list.Model, index int, item list.I
 		line.WriteString("  ")
 	}
 
+	// Tunnels in a group sit under its header
+	if t.Group != "" {
+		line.WriteString("  ")
+	}
+
 	// Status icon
 	line.WriteString(statusIcon)
 	line.WriteString(" ")
@@ -101,6 +201,9 @@ func (d TunnelDelegate) Render(w io.Writer, m list.Model, index int, item list.I
 	if t.Ephemeral && d.ShowEphemeral {
 		name = name + " " + ephemeralStyle.Render(IconEphemeral)
 	}
+	if t.RestartPending {
+		name = name + " " + statusConnectingStyle.Render(IconPending)
+	}
 
 	if isSelected {
 		line.WriteString(selectedStyle.Render(name))
@@ -112,11 +215,42 @@ func (d TunnelDelegate) Render(w io.Writer, m list.Model, index int, item list.I
 	fmt.Fprint(w, line.String())
 }
 
+// renderGroup renders a group header with its aggregate status
+func (d TunnelDelegate) renderGroup(w io.Writer, g GroupItem, isSelected bool) {
+	var line strings.Builder
+
+	if isSelected {
+		line.WriteString(cursorStyle.Render("> "))
+	} else {
+		line.WriteString("  ")
+	}
+
+	chevron := IconExpanded
+	if g.Collapsed {
+		chevron = IconCollapsed
+	}
+	line.WriteString(mutedStyle.Render(chevron))
+	line.WriteString(" ")
+	line.WriteString(GroupStatusIcon(g.Status()))
+	line.WriteString(" ")
+
+	if isSelected {
+		line.WriteString(selectedStyle.Bold(true).Render(g.Name))
+	} else {
+		line.WriteString(normalStyle.Bold(true).Render(g.Name))
+	}
+	line.WriteString(mutedStyle.Render(fmt.Sprintf(" %d/%d", g.Connected(), len(g.Tunnels))))
+
+	fmt.Fprint(w, line.String())
+}
+
 // TunnelListPanel wraps a bubbles/list with panel styling
 type TunnelListPanel struct {
-	list   list.Model
-	width  int
-	height int
+	list      list.Model
+	tunnels   []TunnelItem    // All tunnels, including those in collapsed groups
+	collapsed map[string]bool // Groups whose tunnels are hidden
+	width     int
+	height    int
 }
 
 // NewTunnelListPanel creates a new tunnel list panel
@@ -140,7 +274,8 @@ func NewTunnelListPanel() TunnelListPanel {
 	l.Styles.NoItems = mutedStyle
 
 	return TunnelListPanel{
-		list: l,
+		list:      l,
+		collapsed: make(map[string]bool),
 	}
 }
 
@@ -163,13 +298,69 @@ func (p *TunnelListPanel) SetSize(w, h int) {
 
 // SetItems updates the tunnel list
 func (p *TunnelListPanel) SetItems(items []TunnelItem) {
-	listItems := make([]list.Item, len(items))
-	for i, item := range items {
-		listItems[i] = item
+	p.tunnels = items
+	p.refresh()
+}
+
+// refresh rebuilds the list rows: tunnels without a group first, then a
+// header for each group followed by its tunnels unless it is collapsed
+func (p *TunnelListPanel) refresh() {
+	sorted := slices.Clone(p.tunnels)
+	slices.SortStableFunc(sorted, func(a, b TunnelItem) int {
+		return cmp.Or(cmp.Compare(a.Group, b.Group), cmp.Compare(a.Name, b.Name))
+	})
+
+	var listItems []list.Item
+	for i := 0; i < len(sorted); {
+		t := sorted[i]
+		if t.Group == "" {
+			listItems = append(listItems, t)
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(sorted) && sorted[j].Group == t.Group {
+			j++
+		}
+		group := GroupItem{Name: t.Group, Tunnels: sorted[i:j], Collapsed: p.collapsed[t.Group]}
+		listItems = append(listItems, group)
+		if !group.Collapsed {
+			for _, member := range group.Tunnels {
+				listItems = append(listItems, member)
+			}
+		}
+		i = j
 	}
 	p.list.SetItems(listItems)
 }
 
+// ToggleCollapsed collapses or expands the selected group. With a
+// tunnel selected, its group is collapsed.
+func (p *TunnelListPanel) ToggleCollapsed() {
+	var name string
+	switch item := p.list.SelectedItem().(type) {
+	case GroupItem:
+		name = item.Name
+	case TunnelItem:
+		name = item.Group
+	}
+	if name == "" {
+		return
+	}
+
+	p.collapsed[name] = !p.collapsed[name]
+	p.refresh()
+
+	// Keep the cursor on the group's header
+	for i, item := range p.list.Items() {
+		if g, ok := item.(GroupItem); ok && g.Name == name {
+			p.list.Select(i)
+			break
+		}
+	}
+}
+
 // Update handles list updates
 func (p *TunnelListPanel) Update(msg tea.Msg) tea.Cmd {
 	var cmd tea.Cmd
@@ -177,6 +368,15 @@ func (p *TunnelListPanel) Update(msg tea.Msg) tea.Cmd {
 	return cmd
 }
 
+// SelectedGroup returns the selected group header, if one is selected
+func (p *TunnelListPanel) SelectedGroup() *GroupItem {
+	g, ok := p.list.SelectedItem().(GroupItem)
+	if !ok {
+		return nil
+	}
+	return &g
+}
+
 // SelectedItem returns the currently selected tunnel
 func (p *TunnelListPanel) SelectedItem() *TunnelItem {
 	item := p.list.SelectedItem()
@@ -190,6 +390,20 @@ func (p *TunnelListPanel) SelectedItem() *TunnelItem {
 	return &t
 }
 
+// SharedWith returns the other connected tunnels using the same SSH connection as t
+func (p *TunnelListPanel) SharedWith(t TunnelItem) []string {
+	if t.Connection == "" {
+		return nil
+	}
+	var names []string
+	for _, other := range p.Items() {
+		if other.Name != t.Name && other.Connection == t.Connection {
+			names = append(names, other.Name)
+		}
+	}
+	return names
+}
+
 // SelectedIndex returns the currently selected index
 func (p *TunnelListPanel) SelectedIndex() int {
 	return p.list.Index()
@@ -209,14 +423,7 @@ func (p TunnelListPanel) View() string {
 		Render(p.list.View())
 }
 
-// Items returns all items in the list
+// Items returns all tunnels, including those in collapsed groups
 func (p *TunnelListPanel) Items() []TunnelItem {
-	items := p.list.Items()
-	tunnels := make([]TunnelItem, 0, len(items))
-	for _, item := range items {
-		if t, ok := item.(TunnelItem); ok {
-			tunnels = append(tunnels, t)
-		}
-	}
-	return tunnels
+	return slices.Clone(p.tunnels)
 }
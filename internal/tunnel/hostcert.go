@@ -0,0 +1,200 @@
+package tunnel
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostCertificateError is returned when a host presents a certificate
+// signed by a trusted CA that doesn't pass verification, e.g. because it
+// has expired, names other hosts or is revoked
+type HostCertificateError struct {
+	Host string
+	Err  error
+}
+
+func (e *HostCertificateError) Error() string {
+	return fmt.Sprintf("host certificate for %s rejected: %v", e.Host, strings.TrimPrefix(e.Err.Error(), "ssh: "))
+}
+
+func (e *HostCertificateError) Unwrap() error {
+	return e.Err
+}
+
+// hostAuthorities holds the @cert-authority and @revoked entries of
+// known_hosts files
+type hostAuthorities struct {
+	authorities []hostAuthority
+	revoked     map[string]bool // Marshaled revoked keys
+}
+
+// hostAuthority is a CA trusted to sign host keys for matching hosts
+type hostAuthority struct {
+	patterns []string
+	key      ssh.PublicKey
+	filename string
+	line     int
+}
+
+// loadHostAuthorities reads the marked entries of the given known_hosts files
+func loadHostAuthorities(files []string) (*hostAuthorities, error) {
+	a := &hostAuthorities{revoked: make(map[string]bool)}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, line := range strings.Split(string(data), "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "@") {
+				continue
+			}
+			// knownhosts.New has already rejected malformed lines
+			marker, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+			if err != nil {
+				continue
+			}
+
+			switch marker {
+			case "cert-authority":
+				a.authorities = append(a.authorities, hostAuthority{patterns: hosts, key: key, filename: path, line: i + 1})
+			case "revoked":
+				a.revoked[string(key.Marshal())] = true
+			}
+		}
+	}
+	return a, nil
+}
+
+// hasAuthority reports whether any CA signs keys for addr (host:port)
+func (a *hostAuthorities) hasAuthority(addr string) bool {
+	host := knownhosts.Normalize(addr)
+	for _, authority := range a.authorities {
+		if matchHostPatterns(authority.patterns, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostKeys drops CA entries from keys that knownhosts found for a host,
+// leaving its own host keys
+func (a *hostAuthorities) hostKeys(keys []knownhosts.KnownKey) []knownhosts.KnownKey {
+	return slices.DeleteFunc(slices.Clone(keys), func(k knownhosts.KnownKey) bool {
+		return slices.ContainsFunc(a.authorities, func(authority hostAuthority) bool {
+			return authority.filename == k.Filename && authority.line == k.Line
+		})
+	})
+}
+
+// isAuthority reports whether key is a CA for addr (host:port).
+// Implements ssh.CertChecker.IsHostAuthority.
+func (a *hostAuthorities) isAuthority(key ssh.PublicKey, addr string) bool {
+	host := knownhosts.Normalize(addr)
+	for _, authority := range a.authorities {
+		if keysEqual(authority.key, key) && matchHostPatterns(authority.patterns, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRevoked reports whether a certificate, the key it certifies or the
+// CA that signed it is revoked. Implements ssh.CertChecker.IsRevoked.
+func (a *hostAuthorities) isRevoked(cert *ssh.Certificate) bool {
+	for _, key := range []ssh.PublicKey{cert, cert.Key, cert.SignatureKey} {
+		if a.revoked[string(key.Marshal())] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCert verifies a host certificate against the CAs: its signer,
+// principals, validity period and revocation
+func (a *hostAuthorities) checkCert(addr string, remote net.Addr, cert *ssh.Certificate) error {
+	checker := ssh.CertChecker{
+		IsHostAuthority: a.isAuthority,
+		IsRevoked:       a.isRevoked,
+	}
+	if err := checker.CheckHostKey(addr, remote, cert); err != nil {
+		return &HostCertificateError{Host: addr, Err: err}
+	}
+	return nil
+}
+
+// matchHostPatterns matches a normalized host against a known_hosts host
+// list: any pattern must match and no negated (!) pattern may
+func matchHostPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+			if matchHost(negated, host) {
+				return false
+			}
+		} else if matchHost(pattern, host) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchHost matches a host against one pattern, which may be hashed
+// (|1|salt|hash) or use * and ? wildcards
+func matchHost(pattern, host string) bool {
+	if hashed, ok := strings.CutPrefix(pattern, "|1|"); ok {
+		salt64, hash64, ok := strings.Cut(hashed, "|")
+		if !ok {
+			return false
+		}
+		salt, err1 := base64.StdEncoding.DecodeString(salt64)
+		hash, err2 := base64.StdEncoding.DecodeString(hash64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		mac := hmac.New(sha1.New, salt)
+		mac.Write([]byte(host))
+		return hmac.Equal(mac.Sum(nil), hash)
+	}
+	return wildcardMatch(strings.ToLower(pattern), strings.ToLower(host))
+}
+
+// wildcardMatch matches s against a pattern where * matches any run of
+// characters and ? any single character
+func wildcardMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if wildcardMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+// keysEqual reports whether two public keys are the same key
+func keysEqual(a, b ssh.PublicKey) bool {
+	return string(a.Marshal()) == string(b.Marshal())
+}
@@ -0,0 +1,285 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyPolicy controls how unknown and changed SSH host keys are handled
+type HostKeyPolicy string
+
+const (
+	// HostKeyAsk asks an attached client to confirm keys for new hosts
+	HostKeyAsk HostKeyPolicy = "ask"
+	// HostKeyStrict rejects any host whose key is not already in known_hosts
+	HostKeyStrict HostKeyPolicy = "strict"
+	// HostKeyAcceptNew trusts and records keys for new hosts, but rejects changed keys
+	HostKeyAcceptNew HostKeyPolicy = "accept-new"
+	// HostKeyOff disables host key verification entirely
+	HostKeyOff HostKeyPolicy = "off"
+)
+
+// DefaultHostKeyPolicy is used when a tunnel does not configure a policy.
+// Unlike ask, it works without a client attached (autostart, restore).
+const DefaultHostKeyPolicy = HostKeyAcceptNew
+
+// ParseHostKeyPolicy validates a policy string from config.
+// An empty string returns DefaultHostKeyPolicy.
+func ParseHostKeyPolicy(s string) (HostKeyPolicy, error) {
+	switch p := HostKeyPolicy(s); p {
+	case "":
+		return DefaultHostKeyPolicy, nil
+	case HostKeyAsk, HostKeyStrict, HostKeyAcceptNew, HostKeyOff:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown host key policy %q (expected ask, strict, accept-new or off)", s)
+	}
+}
+
+// ErrHostKeyRejected is returned when a user declines an unknown host key
+var ErrHostKeyRejected = errors.New("host key rejected")
+
+// HostKeyPrompt describes an unknown host key awaiting confirmation
+type HostKeyPrompt struct {
+	Host        string // host:port as dialed
+	KeyType     string // e.g. "ssh-ed25519"
+	Fingerprint string // SHA256 fingerprint
+}
+
+// HostKeyConfirmFunc asks the user whether to trust an unknown host key.
+// It blocks until the user answers, the context is cancelled, or it gives up.
+type HostKeyConfirmFunc func(ctx context.Context, prompt HostKeyPrompt) (bool, error)
+
+// HostKeyMismatchError is returned when a host presents a key that differs
+// from the one recorded in known_hosts
+type HostKeyMismatchError struct {
+	Host        string
+	KeyType     string
+	Fingerprint string
+	Known       []knownhosts.KnownKey
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	msg := fmt.Sprintf("host key mismatch for %s: got %s %s", e.Host, e.KeyType, e.Fingerprint)
+	if len(e.Known) > 0 {
+		msg += fmt.Sprintf(", expected key from %s:%d", e.Known[0].Filename, e.Known[0].Line)
+	}
+	return msg
+}
+
+// HostKeyUnknownError is returned when a host has no entry in known_hosts
+// and the policy doesn't allow trusting it
+type HostKeyUnknownError struct {
+	Host        string
+	KeyType     string
+	Fingerprint string
+}
+
+func (e *HostKeyUnknownError) Error() string {
+	return fmt.Sprintf("host key for %s is not in known_hosts (%s %s)", e.Host, e.KeyType, e.Fingerprint)
+}
+
+// knownHostsMu serializes appends to known_hosts files across tunnels
+var knownHostsMu sync.Mutex
+
+// hostKeyVerifier checks host keys against known_hosts according to a policy
+type hostKeyVerifier struct {
+	ctx     context.Context
+	policy  HostKeyPolicy
+	files   []string            // first entry receives newly trusted keys
+	check   ssh.HostKeyCallback // raw known_hosts lookup, nil when policy is off
+	cas     *hostAuthorities    // @cert-authority and @revoked entries
+	confirm HostKeyConfirmFunc  // used by the ask policy, nil means nobody can answer
+}
+
+// newHostKeyVerifier loads the given known_hosts files for verification
+func newHostKeyVerifier(ctx context.Context, policy HostKeyPolicy, files []string, confirm HostKeyConfirmFunc) (*hostKeyVerifier, error) {
+	v := &hostKeyVerifier{ctx: ctx, policy: policy, files: files, confirm: confirm}
+	if policy == HostKeyOff {
+		return v, nil
+	}
+
+	check, err := knownhosts.New(existingFiles(files)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read known_hosts: %w", err)
+	}
+	v.check = check
+
+	v.cas, err = loadHostAuthorities(existingFiles(files))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read known_hosts: %w", err)
+	}
+
+	return v, nil
+}
+
+// Callback implements ssh.HostKeyCallback
+func (v *hostKeyVerifier) Callback(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	if v.check == nil {
+		return nil
+	}
+
+	// Certificates signed by a trusted CA are verified against it, so
+	// new hosts don't need confirming. Others fall back to checking the
+	// certified key itself like any other host key.
+	if cert, ok := key.(*ssh.Certificate); ok {
+		if v.cas.isAuthority(cert.SignatureKey, hostname) {
+			return v.cas.checkCert(hostname, remote, cert)
+		}
+		key = cert.Key
+	}
+
+	err := v.check(hostname, remote, key)
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return err
+	}
+
+	// knownhosts counts CA entries as keys of the hosts they match, but
+	// only the host's own keys make a different key a mismatch
+	if known := v.cas.hostKeys(keyErr.Want); len(known) > 0 {
+		return &HostKeyMismatchError{
+			Host:        hostname,
+			KeyType:     key.Type(),
+			Fingerprint: ssh.FingerprintSHA256(key),
+			Known:       known,
+		}
+	}
+
+	switch {
+	case v.policy == HostKeyAcceptNew:
+	case v.policy == HostKeyAsk && v.confirm != nil:
+		accepted, err := v.confirm(v.ctx, HostKeyPrompt{
+			Host:        hostname,
+			KeyType:     key.Type(),
+			Fingerprint: ssh.FingerprintSHA256(key),
+		})
+		if err != nil {
+			return fmt.Errorf("host key confirmation for %s failed: %w", hostname, err)
+		}
+		if !accepted {
+			return fmt.Errorf("%w for %s", ErrHostKeyRejected, hostname)
+		}
+	default:
+		return &HostKeyUnknownError{
+			Host:        hostname,
+			KeyType:     key.Type(),
+			Fingerprint: ssh.FingerprintSHA256(key),
+		}
+	}
+
+	// Nowhere to record the key, but it has been trusted for this connection
+	if len(v.files) == 0 {
+		return nil
+	}
+
+	return appendKnownHost(v.files[0], hostname, key)
+}
+
+// appendKnownHost records a host key in OpenSSH known_hosts format
+func appendKnownHost(path, hostname string, key ssh.PublicKey) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("unable to create %s: %w", filepath.Dir(path), err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open known_hosts: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	line := knownhosts.Line([]string{hostname}, key)
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		return fmt.Errorf("unable to write known_hosts: %w", err)
+	}
+
+	return nil
+}
+
+// Algorithms returns the host key algorithms matching the keys already
+// recorded for addr, so the server doesn't negotiate a key type we have
+// no record of (which knownhosts reports as a mismatch). Certificates
+// come first for hosts with a CA.
+// Returns nil for unknown hosts, leaving the ssh package defaults in place.
+func (v *hostKeyVerifier) Algorithms(addr string) []string {
+	if v.check == nil {
+		return nil
+	}
+
+	// Probe with a throwaway key - it never matches, so KeyError.Want
+	// lists every key we know for this host
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil
+	}
+	probe, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(v.check(addr, &net.TCPAddr{}, probe), &keyErr) {
+		return nil
+	}
+
+	known := v.cas.hostKeys(keyErr.Want)
+	if len(known) == 0 {
+		return nil
+	}
+
+	var algos []string
+	if v.cas.hasAuthority(addr) {
+		algos = append(algos, certAlgorithms...)
+	}
+	seen := make(map[string]bool)
+	for _, known := range known {
+		for _, algo := range algorithmsForKeyType(known.Key.Type()) {
+			if !seen[algo] {
+				seen[algo] = true
+				algos = append(algos, algo)
+			}
+		}
+	}
+	return algos
+}
+
+// certAlgorithms are the host certificate algorithms
+var certAlgorithms = []string{
+	ssh.CertAlgoED25519v01,
+	ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01,
+	ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01,
+}
+
+// algorithmsForKeyType maps a key type to the signature algorithms it can negotiate
+func algorithmsForKeyType(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+// existingFiles filters out paths that don't exist, since knownhosts.New
+// fails on missing files
+func existingFiles(paths []string) []string {
+	var existing []string
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			existing = append(existing, p)
+		}
+	}
+	return existing
+}
@@ -0,0 +1,297 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("failed to convert key: %v", err)
+	}
+	return key
+}
+
+func TestParseHostKeyPolicy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected HostKeyPolicy
+		wantErr  bool
+	}{
+		{input: "", expected: DefaultHostKeyPolicy},
+		{input: "strict", expected: HostKeyStrict},
+		{input: "accept-new", expected: HostKeyAcceptNew},
+		{input: "off", expected: HostKeyOff},
+		{input: "ask", expected: HostKeyAsk},
+		{input: "yes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseHostKeyPolicy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHostKeyPolicy(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseHostKeyPolicy(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHostKeyVerifier_AcceptNew(t *testing.T) {
+	knownHosts := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+	key := newTestHostKey(t)
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}
+
+	v, err := newHostKeyVerifier(context.Background(), HostKeyAcceptNew, []string{knownHosts}, nil)
+	if err != nil {
+		t.Fatalf("newHostKeyVerifier: %v", err)
+	}
+
+	// Unknown host is accepted and recorded
+	if err := v.Callback("bastion.example.com:22", remote, key); err != nil {
+		t.Fatalf("expected new key to be accepted, got %v", err)
+	}
+
+	data, err := os.ReadFile(knownHosts)
+	if err != nil {
+		t.Fatalf("known_hosts not written: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "bastion.example.com ssh-ed25519 ") {
+		t.Errorf("unexpected known_hosts line: %q", data)
+	}
+
+	// A fresh verifier now trusts the recorded key...
+	v, err = newHostKeyVerifier(context.Background(), HostKeyAcceptNew, []string{knownHosts}, nil)
+	if err != nil {
+		t.Fatalf("newHostKeyVerifier: %v", err)
+	}
+	if err := v.Callback("bastion.example.com:22", remote, key); err != nil {
+		t.Errorf("expected recorded key to verify, got %v", err)
+	}
+
+	// ...and rejects a different one
+	err = v.Callback("bastion.example.com:22", remote, newTestHostKey(t))
+	var mismatch *HostKeyMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected HostKeyMismatchError, got %v", err)
+	}
+	if mismatch.Host != "bastion.example.com:22" {
+		t.Errorf("mismatch host = %q", mismatch.Host)
+	}
+
+	// Known key types are preferred during negotiation
+	algos := v.Algorithms("bastion.example.com:22")
+	if len(algos) != 1 || algos[0] != ssh.KeyAlgoED25519 {
+		t.Errorf("Algorithms() = %v, want [%s]", algos, ssh.KeyAlgoED25519)
+	}
+	if algos := v.Algorithms("other.example.com:22"); algos != nil {
+		t.Errorf("Algorithms() for unknown host = %v, want nil", algos)
+	}
+}
+
+func TestHostKeyVerifier_Strict(t *testing.T) {
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+
+	v, err := newHostKeyVerifier(context.Background(), HostKeyStrict, []string{knownHosts}, nil)
+	if err != nil {
+		t.Fatalf("newHostKeyVerifier: %v", err)
+	}
+
+	err = v.Callback("bastion.example.com:22", &net.TCPAddr{}, newTestHostKey(t))
+	var unknown *HostKeyUnknownError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("expected HostKeyUnknownError, got %v", err)
+	}
+
+	if _, err := os.Stat(knownHosts); !os.IsNotExist(err) {
+		t.Error("strict policy must not write known_hosts")
+	}
+}
+
+func TestHostKeyVerifier_Off(t *testing.T) {
+	v, err := newHostKeyVerifier(context.Background(), HostKeyOff, nil, nil)
+	if err != nil {
+		t.Fatalf("newHostKeyVerifier: %v", err)
+	}
+	if err := v.Callback("anything:22", &net.TCPAddr{}, newTestHostKey(t)); err != nil {
+		t.Errorf("expected off policy to accept any key, got %v", err)
+	}
+}
+
+func TestHostKeyVerifier_Ask(t *testing.T) {
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	key := newTestHostKey(t)
+
+	var asked []HostKeyPrompt
+	answer := false
+	confirm := func(_ context.Context, p HostKeyPrompt) (bool, error) {
+		asked = append(asked, p)
+		return answer, nil
+	}
+
+	v, err := newHostKeyVerifier(context.Background(), HostKeyAsk, []string{knownHosts}, confirm)
+	if err != nil {
+		t.Fatalf("newHostKeyVerifier: %v", err)
+	}
+
+	// Rejected keys fail the connection and are not recorded
+	err = v.Callback("bastion.example.com:22", &net.TCPAddr{}, key)
+	if !errors.Is(err, ErrHostKeyRejected) {
+		t.Fatalf("expected ErrHostKeyRejected, got %v", err)
+	}
+	if _, err := os.Stat(knownHosts); !os.IsNotExist(err) {
+		t.Error("rejected key must not be written to known_hosts")
+	}
+
+	// Accepted keys are recorded
+	answer = true
+	if err := v.Callback("bastion.example.com:22", &net.TCPAddr{}, key); err != nil {
+		t.Fatalf("expected accepted key to pass, got %v", err)
+	}
+	if _, err := os.Stat(knownHosts); err != nil {
+		t.Errorf("accepted key not written: %v", err)
+	}
+
+	if len(asked) != 2 {
+		t.Fatalf("expected 2 prompts, got %d", len(asked))
+	}
+	if asked[0].Fingerprint != ssh.FingerprintSHA256(key) || asked[0].KeyType != ssh.KeyAlgoED25519 {
+		t.Errorf("unexpected prompt: %+v", asked[0])
+	}
+}
+
+func TestHostKeyVerifier_AskWithoutClient(t *testing.T) {
+	v, err := newHostKeyVerifier(context.Background(), HostKeyAsk, nil, nil)
+	if err != nil {
+		t.Fatalf("newHostKeyVerifier: %v", err)
+	}
+
+	err = v.Callback("bastion.example.com:22", &net.TCPAddr{}, newTestHostKey(t))
+	var unknown *HostKeyUnknownError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("expected HostKeyUnknownError when nobody can answer, got %v", err)
+	}
+}
+
+// newTestHostCert signs a host certificate for principals with ca
+func newTestHostCert(t *testing.T, ca ssh.Signer, principals []string, validBefore time.Time) *ssh.Certificate {
+	t.Helper()
+	cert := &ssh.Certificate{
+		Key:             newTestHostKey(t),
+		CertType:        ssh.HostCert,
+		ValidPrincipals: principals,
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("failed to sign certificate: %v", err)
+	}
+	return cert
+}
+
+func TestHostKeyVerifier_CertAuthority(t *testing.T) {
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCA, err := ssh.NewSignerFromKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revokedCert := newTestHostCert(t, ca, []string{"bastion-9.example.com"}, time.Now().Add(time.Hour))
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	content := "@cert-authority *.example.com,!*.internal.example.com " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(ca.PublicKey()))) + "\n" +
+		"@revoked * " + string(ssh.MarshalAuthorizedKey(revokedCert.Key))
+	if err := os.WriteFile(knownHosts, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := newHostKeyVerifier(context.Background(), HostKeyStrict, []string{knownHosts}, nil)
+	if err != nil {
+		t.Fatalf("newHostKeyVerifier: %v", err)
+	}
+
+	valid := time.Now().Add(time.Hour)
+	tests := []struct {
+		name    string
+		host    string
+		cert    *ssh.Certificate
+		wantErr any
+	}{
+		{"signed by CA", "bastion-7.example.com:22", newTestHostCert(t, ca, []string{"bastion-7.example.com"}, valid), nil},
+		{"other principal", "bastion-8.example.com:22", newTestHostCert(t, ca, []string{"bastion-7.example.com"}, valid), new(*HostCertificateError)},
+		{"expired", "bastion-7.example.com:22", newTestHostCert(t, ca, []string{"bastion-7.example.com"}, time.Now().Add(-time.Minute)), new(*HostCertificateError)},
+		{"revoked key", "bastion-9.example.com:22", revokedCert, new(*HostCertificateError)},
+		{"negated host", "db.internal.example.com:22", newTestHostCert(t, ca, []string{"db.internal.example.com"}, valid), new(*HostKeyUnknownError)},
+		{"unknown CA", "bastion-7.example.com:22", newTestHostCert(t, otherCA, []string{"bastion-7.example.com"}, valid), new(*HostKeyUnknownError)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Callback(tt.host, &net.TCPAddr{}, tt.cert)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("Callback() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.As(err, tt.wantErr) {
+				t.Errorf("Callback() error = %v, want %T", err, tt.wantErr)
+			}
+		})
+	}
+
+	// A CA entry isn't a host key, so certificates aren't ruled out
+	if algos := v.Algorithms("bastion-7.example.com:22"); algos != nil {
+		t.Errorf("Algorithms() for host with only a CA = %v, want nil", algos)
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	hashed := knownhosts.HashHostname("bastion.example.com")
+
+	tests := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{"*.example.com", "bastion.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"bastion-?.example.com", "bastion-1.example.com", true},
+		{"[*.example.com]:2222", "[bastion.example.com]:2222", true},
+		{"*.example.com", "[bastion.example.com]:2222", false},
+		{"BASTION.example.com", "bastion.example.com", true},
+		{hashed, "bastion.example.com", true},
+		{hashed, "other.example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchHost(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("matchHost(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Keepalive defaults, matching common ServerAliveInterval/ServerAliveCountMax setups
+const (
+	DefaultKeepaliveInterval = 30 * time.Second
+	DefaultKeepaliveCountMax = 3
+)
+
+// errKeepaliveTimeout is returned when the server stops answering keepalives
+var errKeepaliveTimeout = errors.New("server stopped answering keepalives")
+
+// keepalive sends keepalive@openssh.com requests every interval until ctx is
+// cancelled. If countMax requests in a row go unanswered, the connection is
+// considered dead and an error is returned.
+func keepalive(ctx context.Context, conn ssh.Conn, interval time.Duration, countMax int) error {
+	if countMax <= 0 {
+		countMax = DefaultKeepaliveCountMax
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	replies := make(chan struct{}, 1)
+	pending := false
+	missed := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-replies:
+			pending = false
+			missed = 0
+
+		case <-ticker.C:
+			// Requests are answered in order, so don't queue more while one is outstanding
+			if pending {
+				missed++
+				if missed >= countMax {
+					return fmt.Errorf("%w (%d missed, %s interval)", errKeepaliveTimeout, missed, interval)
+				}
+				continue
+			}
+
+			pending = true
+			go func() {
+				// Any reply, even a refusal, proves the server is alive
+				if _, _, err := conn.SendRequest("keepalive@openssh.com", true, nil); err == nil {
+					replies <- struct{}{}
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestConn connects an SSH client to an in-memory server. If answer is
+// false the server never replies to global requests, like a dead peer.
+func newTestConn(t *testing.T, answer bool) ssh.Conn {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverCfg := &ssh.ServerConfig{NoClientAuth: true}
+	serverCfg.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	clientSide, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = clientSide.Close() })
+
+	serverSide, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = serverSide.Close() })
+
+	go func() {
+		_, chans, reqs, err := ssh.NewServerConn(serverSide, serverCfg)
+		if err != nil {
+			return
+		}
+		go func() {
+			for range chans {
+			}
+		}()
+		for req := range reqs {
+			if answer {
+				_ = req.Reply(false, nil)
+			}
+		}
+	}()
+
+	conn, chans, reqs, err := ssh.NewClientConn(clientSide, "test", &ssh.ClientConfig{
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ssh.NewClient(conn, chans, reqs)
+}
+
+func TestKeepalive_Answered(t *testing.T) {
+	conn := newTestConn(t, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := keepalive(ctx, conn, 5*time.Millisecond, 2); err != nil {
+		t.Errorf("keepalive() = %v, want nil while the server answers", err)
+	}
+}
+
+func TestKeepalive_DeadPeer(t *testing.T) {
+	conn := newTestConn(t, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := keepalive(ctx, conn, 5*time.Millisecond, 3)
+	if !errors.Is(err, errKeepaliveTimeout) {
+		t.Fatalf("keepalive() = %v, want errKeepaliveTimeout", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("dead peer was not detected before the test timeout")
+	}
+}
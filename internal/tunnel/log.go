@@ -0,0 +1,142 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// logCapacity is how many entries a tunnel's log keeps
+const logCapacity = 500
+
+// Level is how serious a log entry is
+type Level string
+
+const (
+	LevelInfo  Level = "info"
+	LevelWarn  Level = "warn"
+	LevelError Level = "error"
+)
+
+// Event is what a log entry is about
+type Event string
+
+const (
+	EventStart     Event = "start"     // Tunnel starting or stopping
+	EventDial      Event = "dial"      // Connecting to an SSH server
+	EventAuth      Event = "auth"      // Authentication methods tried
+	EventConnect   Event = "connect"   // SSH connection up or lost
+	EventListen    Event = "listen"    // Forward listening
+	EventAccept    Event = "accept"    // Accepting forwarded connections
+	EventTarget    Event = "target"    // Reaching a forward's target
+	EventCopy      Event = "copy"      // Moving data between the two ends
+	EventReconnect Event = "reconnect" // Waiting to reconnect
+)
+
+// LogEntry is one event in a tunnel's log
+type LogEntry struct {
+	Time    time.Time
+	Level   Level
+	Event   Event
+	Message string
+}
+
+// Log keeps a tunnel's most recent log entries. Entries are also written
+// to the service log and passed to onEntry as they are added. A nil Log
+// only writes to the service log.
+//
+// Debug entries are too frequent to keep; they only go to the service
+// log, and only when it is at debug level.
+type Log struct {
+	name    string
+	onEntry func(name string, e LogEntry)
+
+	mu      sync.Mutex
+	entries []LogEntry // Ring buffer, oldest at start once full
+	start   int
+}
+
+// newLog creates an empty log for the named tunnel. onEntry may be nil.
+func newLog(name string, onEntry func(name string, e LogEntry)) *Log {
+	return &Log{name: name, onEntry: onEntry}
+}
+
+// Debugf writes a debug message to the service log
+func (l *Log) Debugf(event Event, format string, args ...any) {
+	logger := slog.Default()
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	logger.Debug(fmt.Sprintf(format, args...), l.attrs(event)...)
+}
+
+// Infof records an informational entry
+func (l *Log) Infof(event Event, format string, args ...any) {
+	l.add(LevelInfo, event, fmt.Sprintf(format, args...))
+}
+
+// Warnf records a failure the tunnel carries on from
+func (l *Log) Warnf(event Event, format string, args ...any) {
+	l.add(LevelWarn, event, fmt.Sprintf(format, args...))
+}
+
+// Errorf records a failure that stops the tunnel
+func (l *Log) Errorf(event Event, format string, args ...any) {
+	l.add(LevelError, event, fmt.Sprintf(format, args...))
+}
+
+func (l *Log) add(level Level, event Event, msg string) {
+	slog.Log(context.Background(), level.slogLevel(), msg, l.attrs(event)...)
+	if l == nil {
+		return
+	}
+
+	e := LogEntry{Time: time.Now(), Level: level, Event: event, Message: msg}
+	l.mu.Lock()
+	if len(l.entries) < logCapacity {
+		l.entries = append(l.entries, e)
+	} else {
+		l.entries[l.start] = e
+		l.start = (l.start + 1) % logCapacity
+	}
+	l.mu.Unlock()
+
+	if l.onEntry != nil {
+		l.onEntry(l.name, e)
+	}
+}
+
+// attrs returns the service log attributes for an entry
+func (l *Log) attrs(event Event) []any {
+	if l == nil {
+		return []any{"event", event}
+	}
+	return []any{"tunnel", l.name, "event", event}
+}
+
+// slogLevel returns the service log level for an entry level
+func (l Level) slogLevel() slog.Level {
+	switch l {
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Entries returns the kept entries, oldest first
+func (l *Log) Entries() []LogEntry {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]LogEntry, 0, len(l.entries))
+	entries = append(entries, l.entries[l.start:]...)
+	return append(entries, l.entries[:l.start]...)
+}
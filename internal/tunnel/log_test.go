@@ -0,0 +1,57 @@
+package tunnel
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLog_KeepsMostRecent(t *testing.T) {
+	var notified []string
+	lg := newLog("db", func(name string, e LogEntry) {
+		notified = append(notified, name+": "+e.Message)
+	})
+
+	for i := range logCapacity + 5 {
+		lg.Infof(EventAccept, "entry %d", i)
+	}
+
+	entries := lg.Entries()
+	if len(entries) != logCapacity {
+		t.Fatalf("len(Entries()) = %d, want %d", len(entries), logCapacity)
+	}
+	if first, want := entries[0].Message, "entry 5"; first != want {
+		t.Errorf("oldest entry = %q, want %q", first, want)
+	}
+	if last, want := entries[len(entries)-1].Message, fmt.Sprintf("entry %d", logCapacity+4); last != want {
+		t.Errorf("newest entry = %q, want %q", last, want)
+	}
+	if len(notified) != logCapacity+5 || notified[0] != "db: entry 0" {
+		t.Errorf("onEntry called %d times starting with %q, want every entry", len(notified), notified[0])
+	}
+}
+
+func TestLog_Levels(t *testing.T) {
+	lg := newLog("db", nil)
+	lg.Infof(EventDial, "connecting")
+	lg.Warnf(EventTarget, "refused")
+	lg.Errorf(EventAuth, "denied")
+
+	want := []struct {
+		level Level
+		event Event
+	}{{LevelInfo, EventDial}, {LevelWarn, EventTarget}, {LevelError, EventAuth}}
+	entries := lg.Entries()
+	for i, w := range want {
+		if entries[i].Level != w.level || entries[i].Event != w.event {
+			t.Errorf("entry %d = %s %s, want %s %s", i, entries[i].Level, entries[i].Event, w.level, w.event)
+		}
+	}
+}
+
+func TestLog_Nil(t *testing.T) {
+	var lg *Log
+	lg.Warnf(EventCopy, "no tunnel log")
+	if entries := lg.Entries(); entries != nil {
+		t.Errorf("Entries() = %v, want nil", entries)
+	}
+}
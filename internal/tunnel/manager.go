@@ -2,7 +2,11 @@ package tunnel
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
 	"sync"
 	"time"
 
@@ -13,27 +17,146 @@ import (
 
 // StatusChange represents a tunnel status change event
 type StatusChange struct {
-	Name   string
-	Status State
-	Error  string
+	Name       string
+	Status     State
+	Error      string
+	Attempt    int             // Reconnect attempt number, 0 when not reconnecting
+	NextRetry  time.Time       // When the next reconnect attempt is due (StateReconnecting only)
+	ErrorPhase Phase           // Where connecting failed, if it did
+	ListenAddr string          // Bound listen address once connected
+	Connection string          // SSH connection in use once connected, shared by tunnels with the same value
+	Forwards   []ForwardStatus // State of each forward
+	Removed    bool            // The tunnel was dropped from the config and is gone now
+	Lifecycle  Lifecycle
 }
 
+// Lifecycle records when a tunnel came up and why it last went down
+type Lifecycle struct {
+	StartedAt            time.Time // When the tunnel was last started
+	ConnectedAt          time.Time // When the current connection came up, zero while not connected
+	LastErrorAt          time.Time // When the tunnel last failed
+	LastDisconnectReason string    // Why the last connection went down
+	Restarts             int       // Times the tunnel came up again after its first connection
+	Reconnects           int       // Of those, times it came back up by reconnecting automatically
+}
+
+// disconnectReason describes why a connection went down
+func disconnectReason(err error) string {
+	if err == nil || errors.Is(err, ErrTunnelClosed) {
+		return "stopped"
+	}
+	return err.Error()
+}
+
+// errorEvent returns the log event a tunnel error belongs to
+func errorEvent(err error) Event {
+	switch ErrorPhase(err) {
+	case PhaseDial:
+		return EventDial
+	case PhaseAuth:
+		return EventAuth
+	case PhaseListen:
+		return EventListen
+	default:
+		return EventConnect
+	}
+}
+
+// FormatUptime formats how long a tunnel has been up for display,
+// to the second under an hour and coarser after, e.g. "3h12m" or "2d4h"
+func FormatUptime(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Hour:
+		return d.String()
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
+
+// ForwardStatus is the state of one of a tunnel's forwards
+type ForwardStatus struct {
+	Name       string
+	Type       string // As configured ("" means local)
+	Local      string
+	Remote     string
+	ListenAddr string // Bound address while connected
+	Error      string // Why the forward failed to start
+}
+
+// forwardStatuses returns the initial status of each forward of a tunnel
+func forwardStatuses(tc config.TunnelConfig) []ForwardStatus {
+	var statuses []ForwardStatus
+	for _, fc := range tc.ForwardList() {
+		statuses = append(statuses, ForwardStatus{
+			Name:   fc.Name,
+			Type:   fc.Type,
+			Local:  fc.Local,
+			Remote: fc.Remote,
+		})
+	}
+	return statuses
+}
+
+// ForwardsFromConfig builds the forwards for a tunnel config
+func ForwardsFromConfig(tc config.TunnelConfig) ([]Forward, error) {
+	var forwards []Forward
+	for _, fc := range tc.ForwardList() {
+		kind, err := ParseKind(fc.Type)
+		if err != nil {
+			return nil, fmt.Errorf("forward %s: %w", fc.Name, err)
+		}
+		forwards = append(forwards, Forward{
+			Name:       fc.Name,
+			Kind:       kind,
+			LocalAddr:  fc.Local,
+			RemoteAddr: fc.Remote,
+		})
+	}
+	return forwards, nil
+}
+
+// DefaultStartTimeout is how long Start waits for a tunnel when not configured
+const DefaultStartTimeout = 30 * time.Second
+
 // Manager manages multiple tunnels and tracks their state
 type Manager struct {
 	mu       sync.RWMutex
 	tunnels  map[string]*ManagedTunnel
 	config   *config.Config
-	onChange func(StatusChange) // callback for status changes
+	pool     *Pool                         // SSH connections shared between tunnels
+	onChange func(StatusChange)            // callback for status changes
+	onLog    func(name string, e LogEntry) // callback for tunnel log entries
 }
 
 // ManagedTunnel represents a tunnel being managed by the Manager
 type ManagedTunnel struct {
-	Config    config.TunnelConfig
-	Status    State
-	Error     string
-	Ephemeral bool // true for ad-hoc tunnels created via CLI flags
-	cancel    context.CancelFunc
-	startedAt time.Time
+	Config     config.TunnelConfig
+	Status     State
+	Error      string
+	Ephemeral  bool            // true for ad-hoc tunnels created via CLI flags
+	Attempt    int             // Current reconnect attempt, 0 once connected
+	NextRetry  time.Time       // When the next reconnect attempt is due
+	ErrorPhase Phase           // Where connecting failed, if it did
+	ListenAddr string          // Bound listen address while connected (remote side for remote forwards)
+	Connection string          // SSH connection in use while connected
+	Forwards   []ForwardStatus // State of each forward
+	Stats      StatsSnapshot   // Traffic since the service started, in snapshots only
+	Lifecycle  Lifecycle       // Kept across restarts
+
+	// RestartPending is set when the config changed while the tunnel was
+	// active. The new config (or removal) applies once it stops.
+	RestartPending bool
+	pendingConfig  *config.TunnelConfig
+	pendingRemove  bool
+
+	ctx          context.Context // Current run, cancelled when it is stopped
+	cancel       context.CancelFunc
+	stats        *Stats // Created on first start
+	log          *Log   // Created when first needed
+	hasConnected bool   // Connected at least once, so later connections count as restarts
 }
 
 // NewManager creates a new tunnel manager
@@ -41,13 +164,15 @@ func NewManager(cfg *config.Config) *Manager {
 	m := &Manager{
 		tunnels: make(map[string]*ManagedTunnel),
 		config:  cfg,
+		pool:    NewPool(),
 	}
 
 	// Initialize all configured tunnels as disconnected
 	for _, tc := range cfg.Tunnels {
 		m.tunnels[tc.Name] = &ManagedTunnel{
-			Config: tc,
-			Status: StateDisconnected,
+			Config:   tc,
+			Status:   StateDisconnected,
+			Forwards: forwardStatuses(tc),
 		}
 	}
 
@@ -61,6 +186,59 @@ func (m *Manager) SetOnChange(fn func(StatusChange)) {
 	m.onChange = fn
 }
 
+// SetOnLog sets the callback for entries added to any tunnel's log
+func (m *Manager) SetOnLog(fn func(name string, e LogEntry)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onLog = fn
+}
+
+// notifyLog passes a log entry to the callback. Tunnels must not log
+// while holding m.mu.
+func (m *Manager) notifyLog(name string, e LogEntry) {
+	m.mu.RLock()
+	onLog := m.onLog
+	m.mu.RUnlock()
+
+	if onLog != nil {
+		onLog(name, e)
+	}
+}
+
+// logFor returns the tunnel's log, creating it if needed. The caller
+// must hold m.mu.
+func (m *Manager) logFor(mt *ManagedTunnel) *Log {
+	if mt.log == nil {
+		mt.log = newLog(mt.Config.Name, m.notifyLog)
+	}
+	return mt.log
+}
+
+// Log returns a tunnel's log, e.g. to record how it authenticates
+func (m *Manager) Log(name string) (*Log, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mt, exists := m.tunnels[name]
+	if !exists {
+		return nil, false
+	}
+	return m.logFor(mt), true
+}
+
+// Logs returns the kept entries of a tunnel's log, oldest first
+func (m *Manager) Logs(name string) ([]LogEntry, bool) {
+	m.mu.RLock()
+	mt, exists := m.tunnels[name]
+	var lg *Log
+	if exists {
+		lg = mt.log
+	}
+	m.mu.RUnlock()
+
+	return lg.Entries(), exists
+}
+
 // notifyChange notifies subscribers of a status change
 func (m *Manager) notifyChange(name string, status State, errMsg string) {
 	if m.onChange != nil {
@@ -72,8 +250,10 @@ func (m *Manager) notifyChange(name string, status State, errMsg string) {
 	}
 }
 
-// Start starts a tunnel by name
-func (m *Manager) Start(name string, authMethods []ssh.AuthMethod, sshHost, sshUser string) error {
+// Start starts a tunnel by name, with the connection details and
+// forwards in t. It blocks until the tunnel is connected, has failed, or the configured
+// start timeout passes; the outcome is then available from Status.
+func (m *Manager) Start(name string, t Tunnel, authMethods []ssh.AuthMethod) error {
 	m.mu.Lock()
 
 	mt, exists := m.tunnels[name]
@@ -82,75 +262,313 @@ func (m *Manager) Start(name string, authMethods []ssh.AuthMethod, sshHost, sshU
 		return fmt.Errorf("tunnel %q not found", name)
 	}
 
-	if mt.Status.IsActive() {
+	if status := mt.Status; status.IsActive() {
 		m.mu.Unlock()
-		return fmt.Errorf("tunnel %q is already %s", name, mt.Status)
+		return fmt.Errorf("tunnel %q is already %s", name, status)
 	}
 
 	// Update status to connecting
 	mt.Status = StateConnecting
 	mt.Error = ""
-	mt.startedAt = time.Now()
+	mt.ErrorPhase = ""
+	mt.Attempt = 0
+	mt.NextRetry = time.Time{}
+	mt.Forwards = forwardStatuses(mt.Config)
+	mt.Lifecycle.StartedAt = time.Now()
+	if mt.stats == nil {
+		mt.stats = &Stats{}
+	}
+	t.Stats = mt.stats
+	t.Log = m.logFor(mt)
 
 	ctx, cancel := context.WithCancel(context.Background())
-	mt.cancel = cancel
+	mt.ctx, mt.cancel = ctx, cancel
+
+	timeout := m.config.Daemon.StartTimeout
+	if timeout <= 0 {
+		timeout = DefaultStartTimeout
+	}
 
+	lifecycle := mt.Lifecycle
 	onChange := m.onChange
 	m.mu.Unlock()
 
+	t.Log.Infof(EventStart, "Starting")
+
 	// Notify connecting
 	if onChange != nil {
-		onChange(StatusChange{Name: name, Status: StateConnecting})
+		onChange(StatusChange{Name: name, Status: StateConnecting, Lifecycle: lifecycle})
 	}
 
-	// Start tunnel in goroutine
-	go func() {
-		t := &Tunnel{
-			SSHHost:    sshHost,
-			SSHUser:    sshUser,
-			RemoteAddr: mt.Config.Remote,
-			LocalAddr:  mt.Config.Local,
-		}
+	// Start tunnel in goroutine, waiting for its first outcome
+	settled := make(chan struct{})
+	var once sync.Once
+	go m.run(ctx, name, mt, t, authMethods, func() { once.Do(func() { close(settled) }) })
 
-		err := Start(ctx, t, authMethods)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-settled:
+	case <-timer.C:
+		// Still connecting (e.g. waiting on a prompt); status updates follow as notifications
+	}
+
+	return nil
+}
 
-		m.mu.Lock()
-		if err != nil && err != ErrTunnelClosed {
-			mt.Status = StateError
-			mt.Error = err.Error()
-		} else {
-			mt.Status = StateDisconnected
-			mt.Error = ""
+// run keeps a tunnel up until it is stopped or fails. If the tunnel has
+// reconnect enabled, a connection that drops after coming up is retried
+// with exponential backoff. settled is called once the tunnel is first
+// connected or has stopped.
+func (m *Manager) run(ctx context.Context, name string, mt *ManagedTunnel, t Tunnel, authMethods []ssh.AuthMethod, settled func()) {
+	defer settled()
+
+	t.Name = name
+	t.Pool = m.pool
+	connection := t.connKey().id()
+
+	rc := mt.Config.Reconnect
+	backoff := NewBackoff(rc)
+
+	// Only tunnels that came up at least once are retried, so a bad
+	// config still fails straight away
+	everConnected := false
+	attempt := 0
+
+	for {
+		t.OnReady = func(listenAddrs []string) {
+			everConnected = true
+			attempt = 0
+			m.markConnected(name, mt, listenAddrs, connection)
+			settled()
 		}
-		mt.cancel = nil
-		status := mt.Status
-		errMsg := mt.Error
-		onChange := m.onChange
-		m.mu.Unlock()
 
-		if onChange != nil {
-			onChange(StatusChange{Name: name, Status: status, Error: errMsg})
+		err := Start(ctx, &t, authMethods)
+		if ctx.Err() != nil {
+			err = ErrTunnelClosed
+		}
+
+		if everConnected && shouldReconnect(rc, err) {
+			if rc.MaxAttempts > 0 && attempt >= rc.MaxAttempts {
+				m.finish(name, mt, fmt.Errorf("gave up after %d reconnect attempts: %w", attempt, err))
+				return
+			}
+
+			attempt++
+			delay := backoff.Delay(attempt)
+			t.Log.Warnf(EventReconnect, "%v; reconnecting in %s (attempt %d)", err, delay.Round(time.Millisecond), attempt)
+
+			m.setReconnecting(name, mt, err, attempt, time.Now().Add(delay))
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				m.finish(name, mt, ErrTunnelClosed)
+				return
+			case <-timer.C:
+			}
+
+			if !m.setConnecting(name, mt) {
+				return
+			}
+			continue
 		}
-	}()
 
-	// Give tunnel a moment to connect or fail
-	time.Sleep(100 * time.Millisecond)
+		m.finish(name, mt, err)
+		return
+	}
+}
+
+// setReconnecting records a pending reconnect attempt and notifies
+func (m *Manager) setReconnecting(name string, mt *ManagedTunnel, err error, attempt int, next time.Time) {
+	m.mu.Lock()
+	mt.Status = StateReconnecting
+	mt.ListenAddr = ""
+	mt.Connection = ""
+	mt.setForwardsDown(err)
+	forwards := slices.Clone(mt.Forwards)
+	mt.Error = err.Error()
+	mt.ErrorPhase = ErrorPhase(err)
+	mt.Attempt = attempt
+	mt.NextRetry = next
+	mt.setDown(err)
+	lifecycle := mt.Lifecycle
+	onChange := m.onChange
+	m.mu.Unlock()
+
+	if onChange != nil {
+		onChange(StatusChange{
+			Name:       name,
+			Status:     StateReconnecting,
+			Error:      err.Error(),
+			Attempt:    attempt,
+			NextRetry:  next,
+			ErrorPhase: ErrorPhase(err),
+			Forwards:   forwards,
+			Lifecycle:  lifecycle,
+		})
+	}
+}
 
+// setConnecting moves a reconnecting tunnel back to connecting.
+// Returns false if the tunnel was stopped in the meantime.
+func (m *Manager) setConnecting(name string, mt *ManagedTunnel) bool {
 	m.mu.Lock()
-	// If still connecting after brief wait, consider it connected
-	if mt.Status == StateConnecting {
-		mt.Status = StateConnected
-		onChange = m.onChange
+	if mt.Status != StateReconnecting {
 		m.mu.Unlock()
+		return false
+	}
+	mt.Status = StateConnecting
+	mt.NextRetry = time.Time{}
+	attempt := mt.Attempt
+	errMsg := mt.Error
+	lifecycle := mt.Lifecycle
+	onChange := m.onChange
+	m.mu.Unlock()
+
+	if onChange != nil {
+		onChange(StatusChange{Name: name, Status: StateConnecting, Error: errMsg, Attempt: attempt, Lifecycle: lifecycle})
+	}
+	return true
+}
+
+// finish records the final state once a tunnel has stopped for good
+func (m *Manager) finish(name string, mt *ManagedTunnel, err error) {
+	var mismatch *HostKeyMismatchError
+	m.mu.Lock()
+	if errors.As(err, &mismatch) {
+		mt.Status = StateHostKeyMismatch
+		mt.Error = err.Error()
+	} else if err != nil && err != ErrTunnelClosed {
+		mt.Status = StateError
+		mt.Error = err.Error()
+	} else {
+		mt.Status = StateDisconnected
+		mt.Error = ""
+	}
+	mt.ErrorPhase = ErrorPhase(err)
+	mt.ListenAddr = ""
+	mt.Connection = ""
+	mt.Attempt = 0
+	mt.NextRetry = time.Time{}
+	mt.ctx, mt.cancel = nil, nil
+	mt.setForwardsDown(err)
+	mt.setDown(err)
+	removed := m.applyPending(name, mt)
+	status := mt.Status
+	errMsg := mt.Error
+	phase := mt.ErrorPhase
+	forwards := slices.Clone(mt.Forwards)
+	lifecycle := mt.Lifecycle
+	lg := mt.log
+	onChange := m.onChange
+	m.mu.Unlock()
+
+	if errMsg != "" {
+		lg.Errorf(errorEvent(err), "%s", errMsg)
+	} else {
+		lg.Infof(EventStart, "Stopped")
+	}
+
+	if onChange != nil {
+		onChange(StatusChange{Name: name, Status: status, Error: errMsg, ErrorPhase: phase, Forwards: forwards, Removed: removed, Lifecycle: lifecycle})
+	}
+}
 
-		if onChange != nil {
-			onChange(StatusChange{Name: name, Status: StateConnected})
+// applyPending applies a config change that arrived while the tunnel was
+// active. Returns true if the tunnel was removed. The caller must hold m.mu.
+func (m *Manager) applyPending(name string, mt *ManagedTunnel) bool {
+	if !mt.RestartPending {
+		return false
+	}
+	removed := mt.pendingRemove
+	if removed {
+		if m.tunnels[name] == mt {
+			delete(m.tunnels, name)
 		}
 	} else {
+		mt.Config = *mt.pendingConfig
+		mt.Forwards = forwardStatuses(mt.Config)
+	}
+	mt.RestartPending = false
+	mt.pendingConfig = nil
+	mt.pendingRemove = false
+	return removed
+}
+
+// markConnected moves a connecting tunnel to connected once it reports ready
+func (m *Manager) markConnected(name string, mt *ManagedTunnel, listenAddrs []string, connection string) {
+	m.mu.Lock()
+	if mt.Status != StateConnecting {
 		m.mu.Unlock()
+		return
+	}
+	mt.Status = StateConnected
+	mt.Error = ""
+	mt.ErrorPhase = ""
+	if mt.Attempt > 0 {
+		mt.Lifecycle.Reconnects++
 	}
+	mt.Attempt = 0
+	mt.Connection = connection
+	mt.Lifecycle.ConnectedAt = time.Now()
+	if mt.hasConnected {
+		mt.Lifecycle.Restarts++
+	}
+	mt.hasConnected = true
+	for i := range mt.Forwards {
+		if i < len(listenAddrs) {
+			mt.Forwards[i].ListenAddr = listenAddrs[i]
+		}
+		mt.Forwards[i].Error = ""
+	}
+	if len(listenAddrs) > 0 {
+		mt.ListenAddr = listenAddrs[0]
+	}
+	listenAddr := mt.ListenAddr
+	forwards := slices.Clone(mt.Forwards)
+	lifecycle := mt.Lifecycle
+	onChange := m.onChange
+	m.mu.Unlock()
 
-	return nil
+	if onChange != nil {
+		onChange(StatusChange{
+			Name:       name,
+			Status:     StateConnected,
+			ListenAddr: listenAddr,
+			Connection: connection,
+			Forwards:   forwards,
+			Lifecycle:  lifecycle,
+		})
+	}
+}
+
+// setForwardsDown clears the forwards' listen addresses once the tunnel
+// is no longer connected, and records which forward failed to start.
+// The caller must hold m.mu.
+func (mt *ManagedTunnel) setForwardsDown(err error) {
+	failed := FailedForward(err)
+	for i := range mt.Forwards {
+		mt.Forwards[i].ListenAddr = ""
+		mt.Forwards[i].Error = ""
+		if failed != "" && mt.Forwards[i].Name == failed {
+			mt.Forwards[i].Error = err.Error()
+		}
+	}
+}
+
+// setDown records a failure, and why the connection went down if the
+// tunnel was connected. The caller must hold m.mu.
+func (mt *ManagedTunnel) setDown(err error) {
+	if mt.Error != "" {
+		mt.Lifecycle.LastErrorAt = time.Now()
+	}
+	if !mt.Lifecycle.ConnectedAt.IsZero() {
+		mt.Lifecycle.LastDisconnectReason = disconnectReason(err)
+		mt.Lifecycle.ConnectedAt = time.Time{}
+	}
 }
 
 // Stop stops a running tunnel by name.
@@ -191,17 +609,33 @@ func (m *Manager) Stop(name string) error {
 	return nil
 }
 
-// Status returns the status of a tunnel
-func (m *Manager) Status(name string) (State, string) {
+// Context returns the context of a tunnel's current run, which is
+// cancelled when the tunnel is stopped. Prompts raised while connecting
+// use it so stopping the tunnel dismisses them. It is already cancelled
+// if the tunnel isn't running.
+func (m *Manager) Context(name string) context.Context {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if mt, ok := m.tunnels[name]; ok && mt.ctx != nil {
+		return mt.ctx
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+// Get returns a snapshot of a tunnel by name
+func (m *Manager) Get(name string) (ManagedTunnel, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	mt, exists := m.tunnels[name]
 	if !exists {
-		return StateDisconnected, "tunnel not found"
+		return ManagedTunnel{}, false
 	}
 
-	return mt.Status, mt.Error
+	return mt.snapshot(), true
 }
 
 // List returns all managed tunnels
@@ -211,18 +645,109 @@ func (m *Manager) List() []ManagedTunnel {
 
 	result := make([]ManagedTunnel, 0, len(m.tunnels))
 	for _, mt := range m.tunnels {
-		result = append(result, ManagedTunnel{
-			Config:    mt.Config,
-			Status:    mt.Status,
-			Error:     mt.Error,
-			Ephemeral: mt.Ephemeral,
-			startedAt: mt.startedAt,
-		})
+		result = append(result, mt.snapshot())
 	}
 
 	return result
 }
 
+// snapshot copies the tunnel's public state. The caller must hold m.mu.
+func (mt *ManagedTunnel) snapshot() ManagedTunnel {
+	return ManagedTunnel{
+		Config:     mt.Config,
+		Status:     mt.Status,
+		Error:      mt.Error,
+		Ephemeral:  mt.Ephemeral,
+		Attempt:    mt.Attempt,
+		NextRetry:  mt.NextRetry,
+		ErrorPhase: mt.ErrorPhase,
+		ListenAddr: mt.ListenAddr,
+		Connection: mt.Connection,
+		Forwards:   slices.Clone(mt.Forwards),
+		Stats:      mt.stats.Snapshot(),
+		Lifecycle:  mt.Lifecycle,
+
+		RestartPending: mt.RestartPending,
+	}
+}
+
+// ReloadResult describes how a new config changed the managed tunnels
+type ReloadResult struct {
+	Added          []string // New tunnels
+	Removed        []string // Deleted tunnels that were inactive, gone now
+	Updated        []string // Changed tunnels that were inactive, using the new config now
+	RestartPending []string // Changed or deleted tunnels that are active, updated once they stop
+}
+
+// Reload replaces the config, adding new tunnels and updating or removing
+// existing ones. Active tunnels are left running; their changes apply once
+// they stop. Ad-hoc tunnels are not affected.
+func (m *Manager) Reload(cfg *config.Config) ReloadResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result ReloadResult
+	m.config = cfg
+
+	seen := make(map[string]bool)
+	for _, tc := range cfg.Tunnels {
+		seen[tc.Name] = true
+
+		mt, exists := m.tunnels[tc.Name]
+		switch {
+		case !exists:
+			m.tunnels[tc.Name] = &ManagedTunnel{
+				Config:   tc,
+				Status:   StateDisconnected,
+				Forwards: forwardStatuses(tc),
+			}
+			result.Added = append(result.Added, tc.Name)
+
+		case mt.Ephemeral:
+			slog.Warn("Config tunnel clashes with an ad-hoc tunnel, skipping", "tunnel", tc.Name)
+
+		case mt.Status.IsActive():
+			if reflect.DeepEqual(mt.Config, tc) {
+				// Changed back to the running config
+				mt.RestartPending = false
+				mt.pendingConfig = nil
+				mt.pendingRemove = false
+				continue
+			}
+			mt.RestartPending = true
+			mt.pendingConfig = &tc
+			mt.pendingRemove = false
+			result.RestartPending = append(result.RestartPending, tc.Name)
+
+		case !reflect.DeepEqual(mt.Config, tc):
+			mt.Config = tc
+			mt.Forwards = forwardStatuses(tc)
+			result.Updated = append(result.Updated, tc.Name)
+		}
+	}
+
+	for name, mt := range m.tunnels {
+		if mt.Ephemeral || seen[name] {
+			continue
+		}
+		if mt.Status.IsActive() {
+			mt.RestartPending = true
+			mt.pendingConfig = nil
+			mt.pendingRemove = true
+			result.RestartPending = append(result.RestartPending, name)
+			continue
+		}
+		delete(m.tunnels, name)
+		result.Removed = append(result.Removed, name)
+	}
+
+	slices.Sort(result.Added)
+	slices.Sort(result.Removed)
+	slices.Sort(result.Updated)
+	slices.Sort(result.RestartPending)
+	return result
+}
+
 // StopAll stops all running tunnels
 func (m *Manager) StopAll() {
 	m.mu.Lock()
@@ -235,31 +760,35 @@ func (m *Manager) StopAll() {
 	}
 }
 
-// GetConfig returns the config for a tunnel
-func (m *Manager) GetConfig(name string) *config.TunnelConfig {
+// GetConfig returns a copy of a tunnel's config, safe to use while a
+// reload replaces it
+func (m *Manager) GetConfig(name string) (config.TunnelConfig, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	mt, exists := m.tunnels[name]
 	if !exists {
-		return nil
+		return config.TunnelConfig{}, false
 	}
 
-	return &mt.Config
+	return mt.Config.Clone(), true
 }
 
-// Register adds an ad-hoc tunnel to the manager with a generated name.
-// Returns the generated name.
+// Register adds an ad-hoc tunnel to the manager with a generated name,
+// or with tc.Name if it is set and not taken (e.g. when restoring).
+// Returns the name.
 func (m *Manager) Register(tc config.TunnelConfig) (string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Generate a unique name
-	var name string
-	for i := 0; i < 10; i++ {
-		name = namesgenerator.GetRandomName(0)
-		if _, exists := m.tunnels[name]; !exists {
-			break
+	// Keep the requested name if it is free, otherwise generate a unique one
+	name := tc.Name
+	if _, exists := m.tunnels[name]; name == "" || exists {
+		for i := 0; i < 10; i++ {
+			name = namesgenerator.GetRandomName(0)
+			if _, exists := m.tunnels[name]; !exists {
+				break
+			}
 		}
 	}
 
@@ -273,6 +802,7 @@ func (m *Manager) Register(tc config.TunnelConfig) (string, error) {
 		Config:    tc,
 		Status:    StateDisconnected,
 		Ephemeral: true,
+		Forwards:  forwardStatuses(tc),
 	}
 
 	return name, nil
@@ -0,0 +1,145 @@
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/JoshElias/gurren/internal/config"
+)
+
+func TestManager_Reload(t *testing.T) {
+	m := NewManager(&config.Config{Tunnels: []config.TunnelConfig{
+		{Name: "idle", Host: "bastion", Remote: "db:5432", Local: "localhost:5432"},
+		{Name: "backup", Host: "bastion", Remote: "db:5432", Local: "localhost:5442"},
+		{Name: "running", Host: "bastion", Remote: "web:80", Local: "localhost:8080"},
+		{Name: "gone", Host: "bastion", Remote: "cache:6379", Local: "localhost:6379"},
+		{Name: "gone-running", Host: "bastion", Remote: "mq:5672", Local: "localhost:5672"},
+	}})
+	m.tunnels["running"].Status = StateConnected
+	m.tunnels["gone-running"].Status = StateConnected
+
+	result := m.Reload(&config.Config{Tunnels: []config.TunnelConfig{
+		{Name: "idle", Host: "bastion", Remote: "db:5433", Local: "localhost:5432"},
+		{Name: "backup", Host: "bastion", Remote: "db:5433", Local: "localhost:5442"},
+		{Name: "running", Host: "bastion", Remote: "web:81", Local: "localhost:8080"},
+		{Name: "new", Host: "bastion", Remote: "api:443", Local: "localhost:8443"},
+		{Name: "api", Host: "bastion", Remote: "api:80", Local: "localhost:8081"},
+	}})
+
+	check := func(field string, got, want []string) {
+		t.Helper()
+		if !slices.Equal(got, want) {
+			t.Errorf("%s = %v, want %v", field, got, want)
+		}
+	}
+	check("Added", result.Added, []string{"api", "new"})
+	check("Removed", result.Removed, []string{"gone"})
+	check("Updated", result.Updated, []string{"backup", "idle"})
+	check("RestartPending", result.RestartPending, []string{"gone-running", "running"})
+
+	if got := m.tunnels["idle"].Config.Remote; got != "db:5433" {
+		t.Errorf("idle remote = %q, want the new config applied", got)
+	}
+
+	// Active tunnels keep running with the old config until they stop
+	running := m.tunnels["running"]
+	if !running.RestartPending || running.Config.Remote != "web:80" {
+		t.Errorf("running = %+v, want old config with restart pending", running)
+	}
+	m.finish("running", running, nil)
+	if running.RestartPending || running.Config.Remote != "web:81" {
+		t.Errorf("after stop, running = %+v, want new config applied", running)
+	}
+
+	m.finish("gone-running", m.tunnels["gone-running"], nil)
+	if _, ok := m.tunnels["gone-running"]; ok {
+		t.Error("gone-running still managed after stopping")
+	}
+}
+
+func TestManager_ReloadKeepsEphemeral(t *testing.T) {
+	m := NewManager(&config.Config{})
+	name, err := m.Register(config.TunnelConfig{Host: "bastion", Remote: "db:5432", Local: "localhost:5432"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := m.Reload(&config.Config{})
+	if len(result.Removed) > 0 {
+		t.Errorf("Removed = %v, want ad-hoc tunnels left alone", result.Removed)
+	}
+	if _, ok := m.Get(name); !ok {
+		t.Errorf("ad-hoc tunnel %q removed by reload", name)
+	}
+}
+
+func TestManager_Lifecycle(t *testing.T) {
+	m := NewManager(&config.Config{Tunnels: []config.TunnelConfig{
+		{Name: "db", Host: "bastion", Remote: "db:5432", Local: "localhost:5432"},
+	}})
+	mt := m.tunnels["db"]
+
+	connect := func() {
+		t.Helper()
+		mt.Status = StateConnecting
+		m.markConnected("db", mt, nil, "bastion")
+		if mt.Lifecycle.ConnectedAt.IsZero() {
+			t.Fatal("ConnectedAt not set once connected")
+		}
+	}
+
+	connect()
+	if mt.Lifecycle.Restarts != 0 || mt.Lifecycle.Reconnects != 0 {
+		t.Errorf("Restarts = %d, Reconnects = %d after first connection, want 0", mt.Lifecycle.Restarts, mt.Lifecycle.Reconnects)
+	}
+
+	dropped := fmt.Errorf("%w: keepalive timed out", ErrConnectionLost)
+	m.setReconnecting("db", mt, dropped, 1, time.Now())
+	if !mt.Lifecycle.ConnectedAt.IsZero() {
+		t.Error("ConnectedAt still set after the connection dropped")
+	}
+	if mt.Lifecycle.LastDisconnectReason != dropped.Error() || mt.Lifecycle.LastErrorAt.IsZero() {
+		t.Errorf("Lifecycle = %+v, want the drop recorded", mt.Lifecycle)
+	}
+
+	connect()
+	if mt.Lifecycle.Restarts != 1 || mt.Lifecycle.Reconnects != 1 {
+		t.Errorf("Restarts = %d, Reconnects = %d after reconnecting, want 1", mt.Lifecycle.Restarts, mt.Lifecycle.Reconnects)
+	}
+
+	// Starting it again by hand is a restart but not a reconnect
+	m.finish("db", mt, ErrTunnelClosed)
+	connect()
+	if mt.Lifecycle.Restarts != 2 || mt.Lifecycle.Reconnects != 1 {
+		t.Errorf("Restarts = %d, Reconnects = %d after a manual restart, want 2 and 1", mt.Lifecycle.Restarts, mt.Lifecycle.Reconnects)
+	}
+
+	// A failed start doesn't replace the reason the last connection went down
+	m.finish("db", mt, ErrTunnelClosed)
+	if mt.Lifecycle.LastDisconnectReason != "stopped" {
+		t.Errorf("LastDisconnectReason = %q, want %q", mt.Lifecycle.LastDisconnectReason, "stopped")
+	}
+	mt.Status = StateConnecting
+	m.finish("db", mt, errors.New("dial tcp: connection refused"))
+	if mt.Lifecycle.LastDisconnectReason != "stopped" {
+		t.Errorf("LastDisconnectReason = %q after a failed start, want it unchanged", mt.Lifecycle.LastDisconnectReason)
+	}
+}
+
+func TestFormatUptime(t *testing.T) {
+	tests := map[time.Duration]string{
+		42 * time.Second:               "42s",
+		5*time.Minute + 12*time.Second: "5m12s",
+		3*time.Hour + 12*time.Minute:   "3h12m",
+		52*time.Hour + 30*time.Minute:  "2d4h",
+		1500 * time.Millisecond:        "2s",
+	}
+	for d, want := range tests {
+		if got := FormatUptime(d); got != want {
+			t.Errorf("FormatUptime(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
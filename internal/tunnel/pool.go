@@ -0,0 +1,326 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// connKey identifies an SSH connection that tunnels can share: the same
+// server, user and route (jump hosts or proxy command), verified the same
+// way and authenticated with the same credentials. A tunnel must not ride
+// a connection its own host key policy or credentials wouldn't have made.
+type connKey struct {
+	host     string
+	user     string
+	route    string
+	hostKeys string // Host key policy and known_hosts files of every hop
+	auth     string // AuthIdentity of every hop
+}
+
+// connKey returns the key of the connection a tunnel would make
+func (t *Tunnel) connKey() connKey {
+	var hops, hostKeys, auth []string
+	for _, hop := range t.Jumps {
+		hops = append(hops, userHost(hop.SSHUser, hop.SSHHost))
+		hostKeys = append(hostKeys, strings.Join(hop.KnownHostsFiles, ":"))
+		auth = append(auth, hop.AuthIdentity)
+	}
+	hostKeys = append(hostKeys, strings.Join(t.KnownHostsFiles, ":"))
+	auth = append(auth, t.AuthIdentity)
+
+	route := strings.Join(hops, ",")
+	if len(t.Jumps) > 0 && t.Jumps[0].ProxyCommand != "" {
+		route = t.Jumps[0].ProxyCommand + "|" + route
+	} else if len(t.Jumps) == 0 && t.ProxyCommand != "" {
+		route = t.ProxyCommand
+	}
+	return connKey{
+		host:     t.SSHHost,
+		user:     t.SSHUser,
+		route:    route,
+		hostKeys: string(t.HostKeyPolicy) + "|" + strings.Join(hostKeys, ","),
+		auth:     strings.Join(auth, ","),
+	}
+}
+
+// String describes the connection, for display
+func (k connKey) String() string {
+	s := userHost(k.user, k.host)
+	if k.route != "" {
+		s += " via " + k.route
+	}
+	return s
+}
+
+// id identifies the connection to clients: String plus a short digest of
+// the host key and auth settings, so equal ids mean a shared connection
+func (k connKey) id() string {
+	sum := sha256.Sum256([]byte(k.hostKeys + "\x00" + k.auth))
+	return fmt.Sprintf("%s #%x", k, sum[:4])
+}
+
+func userHost(user, host string) string {
+	if user == "" {
+		return host
+	}
+	return user + "@" + host
+}
+
+// sshConn is an SSH connection, with the jump host connections it goes
+// through, used by one or more tunnels
+type sshConn struct {
+	client *ssh.Client
+	jumps  []*ssh.Client
+	proxy  *proxyDialer
+
+	lost   chan struct{}      // Closed once the connection has dropped
+	err    error              // Why the connection dropped, set before lost is closed
+	cancel context.CancelFunc // Stops keepalives
+
+	// Pool bookkeeping, guarded by pool.mu. Connections made without
+	// a pool have a nil pool and are closed when released.
+	pool  *Pool
+	key   connKey
+	users map[string]struct{} // Names of the tunnels using the connection
+}
+
+// dialConn connects to the tunnel's SSH server, through its jump hosts
+// or proxy command, and starts keepalives. pool may be nil.
+func dialConn(ctx context.Context, t *Tunnel, authMethods []ssh.AuthMethod, pool *Pool) (*sshConn, error) {
+	c := &sshConn{
+		lost:  make(chan struct{}),
+		pool:  pool,
+		key:   t.connKey(),
+		users: map[string]struct{}{t.Name: {}},
+	}
+
+	// Connect through each jump host in turn, dialing the next hop
+	// from the previous one
+	var dialer net.Dialer
+	dial := dialer.DialContext
+
+	// The first connection may go through a ProxyCommand instead.
+	// Its stderr is added to errors to explain failures.
+	proxyCommand := t.ProxyCommand
+	if len(t.Jumps) > 0 {
+		proxyCommand = t.Jumps[0].ProxyCommand
+	}
+	if proxyCommand != "" {
+		c.proxy = &proxyDialer{command: proxyCommand}
+		dial = c.proxy.DialContext
+	}
+
+	for i, hop := range t.Jumps {
+		jumpClient, _, err := t.connect(ctx, dial, hop.SSHHost, hop.SSHUser, hop.AuthMethods, hop.KnownHostsFiles)
+		if hop.AuthDone != nil {
+			hop.AuthDone()
+		}
+		if err != nil {
+			c.closeJumps()
+			var tErr *Error
+			if errors.As(err, &tErr) {
+				return nil, &Error{Phase: tErr.Phase, Err: fmt.Errorf("jump host %d (%s): %w", i+1, hop.Name, tErr.Err)}
+			}
+			return nil, err
+		}
+		t.Log.Infof(EventConnect, "Connected to jump host %s", hop.SSHHost)
+		c.jumps = append(c.jumps, jumpClient)
+		dial = jumpClient.DialContext
+	}
+
+	// Connect to SSH server
+	client, handshake, err := t.connect(ctx, dial, t.SSHHost, t.SSHUser, authMethods, t.KnownHostsFiles)
+	if t.AuthDone != nil {
+		t.AuthDone()
+	}
+	if err != nil {
+		c.closeJumps()
+		return nil, err
+	}
+	c.client = client
+	t.Stats.handshake(handshake)
+
+	t.Log.Infof(EventConnect, "Connected to %s", t.SSHHost)
+
+	// Detect half-dead connections (e.g. after suspend) by closing the
+	// client once the server stops answering keepalives
+	kaCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	keepaliveErr := make(chan error, 1)
+	if t.KeepaliveInterval > 0 {
+		go func() {
+			if err := keepalive(kaCtx, client, t.KeepaliveInterval, t.KeepaliveCountMax); err != nil {
+				keepaliveErr <- err
+				_ = client.Close()
+			}
+		}()
+	}
+
+	go func() {
+		_ = client.Wait()
+		cancel()
+
+		// Closing waits for a proxy command to exit, so its stderr is complete
+		_ = client.Close()
+		c.closeJumps()
+
+		select {
+		case err := <-keepaliveErr:
+			c.err = fmt.Errorf("%w: %w", ErrConnectionLost, err)
+		default:
+			c.err = ErrConnectionLost
+		}
+		c.err = c.proxy.withStderr(c.err)
+		close(c.lost)
+
+		if c.pool != nil {
+			c.pool.forget(c)
+		}
+	}()
+
+	return c, nil
+}
+
+// close closes the connection and the jump host connections under it
+func (c *sshConn) close() {
+	c.cancel()
+	if err := c.client.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		slog.Warn("Error closing SSH client", "err", err)
+	}
+	c.closeJumps()
+}
+
+// closeJumps closes the jump host connections, last hop first
+func (c *sshConn) closeJumps() {
+	for _, jump := range slices.Backward(c.jumps) {
+		_ = jump.Close()
+	}
+}
+
+// release gives up a tunnel's use of the connection
+func (c *sshConn) release(name string) {
+	if c.pool == nil {
+		c.close()
+		return
+	}
+	c.pool.release(c, name)
+}
+
+// Pool shares SSH connections between tunnels to the same server, so
+// starting several tunnels needs a single handshake and authentication.
+// A connection is closed once the last tunnel using it stops.
+type Pool struct {
+	mu      sync.Mutex
+	conns   map[connKey]*sshConn
+	dialing map[connKey]*pendingDial
+}
+
+// pendingDial is a connection being established, which other tunnels
+// with the same key wait for instead of dialing themselves
+type pendingDial struct {
+	done chan struct{}
+	err  error
+}
+
+// NewPool creates an empty connection pool
+func NewPool() *Pool {
+	return &Pool{
+		conns:   make(map[connKey]*sshConn),
+		dialing: make(map[connKey]*pendingDial),
+	}
+}
+
+// acquire returns a connection for the tunnel, reusing an existing one
+// when possible. Callers must release it when done.
+func (p *Pool) acquire(ctx context.Context, t *Tunnel, authMethods []ssh.AuthMethod) (*sshConn, error) {
+	key := t.connKey()
+
+	for {
+		p.mu.Lock()
+		if c, ok := p.conns[key]; ok {
+			select {
+			case <-c.lost:
+				// Dropped but not forgotten yet
+				delete(p.conns, key)
+			default:
+				c.users[t.Name] = struct{}{}
+				p.mu.Unlock()
+				t.Log.Infof(EventConnect, "Reusing connection to %s", key)
+				return c, nil
+			}
+		}
+
+		// Another tunnel is already connecting - wait for it rather than
+		// authenticating twice
+		if pending, ok := p.dialing[key]; ok {
+			p.mu.Unlock()
+			select {
+			case <-pending.done:
+			case <-ctx.Done():
+				return nil, ErrTunnelClosed
+			}
+			// If the other tunnel was stopped while connecting, try again ourselves
+			if pending.err != nil && !errors.Is(pending.err, ErrTunnelClosed) {
+				return nil, pending.err
+			}
+			continue
+		}
+
+		pending := &pendingDial{done: make(chan struct{})}
+		p.dialing[key] = pending
+		p.mu.Unlock()
+
+		c, err := dialConn(ctx, t, authMethods, p)
+
+		p.mu.Lock()
+		delete(p.dialing, key)
+		pending.err = err
+		if err == nil {
+			select {
+			case <-c.lost:
+				// Dropped already; don't hand it out again
+			default:
+				p.conns[key] = c
+			}
+		}
+		close(pending.done)
+		p.mu.Unlock()
+
+		return c, err
+	}
+}
+
+// release removes a tunnel from a connection's users, closing the
+// connection if it was the last one
+func (p *Pool) release(c *sshConn, name string) {
+	p.mu.Lock()
+	delete(c.users, name)
+	last := len(c.users) == 0
+	if last && p.conns[c.key] == c {
+		delete(p.conns, c.key)
+	}
+	p.mu.Unlock()
+
+	if last {
+		c.close()
+	}
+}
+
+// forget removes a dropped connection so the next tunnel dials afresh
+func (p *Pool) forget(c *sshConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conns[c.key] == c {
+		delete(p.conns, c.key)
+	}
+}
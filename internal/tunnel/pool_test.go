@@ -0,0 +1,342 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an SSH server accepting any client, which counts
+// handshakes, serves remote forwards on 127.0.0.1 and can drop its
+// connections
+type testServer struct {
+	addr       string
+	handshakes atomic.Int32
+	cancels    atomic.Int32 // cancel-tcpip-forward requests received
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverCfg := &ssh.ServerConfig{NoClientAuth: true}
+	serverCfg.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	s := &testServer{addr: listener.Addr().String()}
+	t.Cleanup(s.dropAll)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+
+			go func() {
+				sshConn, chans, reqs, err := ssh.NewServerConn(conn, serverCfg)
+				if err != nil {
+					return
+				}
+				s.handshakes.Add(1)
+				go s.handleRequests(sshConn, reqs)
+				for range chans {
+				}
+			}()
+		}
+	}()
+
+	return s
+}
+
+// forwardRequest is the payload of tcpip-forward and cancel-tcpip-forward
+type forwardRequest struct {
+	Addr string
+	Port uint32
+}
+
+// forwardedChannel is the payload of a forwarded-tcpip channel
+type forwardedChannel struct {
+	Addr       string
+	Port       uint32
+	OriginAddr string
+	OriginPort uint32
+}
+
+// handleRequests serves remote forwards (tcpip-forward) until the
+// client cancels them, and refuses other global requests
+func (s *testServer) handleRequests(conn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	listeners := make(map[uint32]net.Listener)
+	defer func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}()
+
+	for req := range reqs {
+		var fwd forwardRequest
+		if err := ssh.Unmarshal(req.Payload, &fwd); err != nil {
+			_ = req.Reply(false, nil)
+			continue
+		}
+
+		switch req.Type {
+		case "tcpip-forward":
+			l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", fwd.Port))
+			if err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			port := uint32(l.Addr().(*net.TCPAddr).Port)
+			listeners[port] = l
+			_ = req.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+			go serveForward(conn, l, forwardRequest{Addr: fwd.Addr, Port: port})
+		case "cancel-tcpip-forward":
+			if l, ok := listeners[fwd.Port]; ok {
+				_ = l.Close()
+				delete(listeners, fwd.Port)
+			}
+			s.cancels.Add(1)
+			_ = req.Reply(true, nil)
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+// serveForward hands each connection accepted on l to the client in a
+// forwarded-tcpip channel
+func serveForward(conn *ssh.ServerConn, l net.Listener, fwd forwardRequest) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		origin := c.RemoteAddr().(*net.TCPAddr)
+		ch, reqs, err := conn.OpenChannel("forwarded-tcpip", ssh.Marshal(forwardedChannel{
+			Addr:       fwd.Addr,
+			Port:       fwd.Port,
+			OriginAddr: origin.IP.String(),
+			OriginPort: uint32(origin.Port),
+		}))
+		if err != nil {
+			_ = c.Close()
+			continue
+		}
+		go ssh.DiscardRequests(reqs)
+		go func() {
+			_, _ = io.Copy(ch, c)
+			_ = ch.CloseWrite()
+		}()
+		go func() {
+			_, _ = io.Copy(c, ch)
+			_ = c.Close()
+			_ = ch.Close()
+		}()
+	}
+}
+
+// dropAll closes every connection to the server
+func (s *testServer) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func waitLost(t *testing.T, c *sshConn) {
+	t.Helper()
+	select {
+	case <-c.lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection was not closed")
+	}
+}
+
+func TestPool_SharesConnection(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool()
+	ctx := context.Background()
+
+	a := &Tunnel{Name: "postgres", SSHHost: server.addr, HostKeyPolicy: HostKeyOff}
+	b := &Tunnel{Name: "redis", SSHHost: server.addr, HostKeyPolicy: HostKeyOff}
+
+	connA, err := pool.acquire(ctx, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connB, err := pool.acquire(ctx, b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if connA != connB {
+		t.Fatal("tunnels to the same server got different connections")
+	}
+	if got := server.handshakes.Load(); got != 1 {
+		t.Errorf("handshakes = %d, want 1", got)
+	}
+
+	// The connection stays up until its last tunnel releases it
+	connA.release(a.Name)
+	select {
+	case <-connA.lost:
+		t.Fatal("connection closed while still in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	connB.release(b.Name)
+	waitLost(t, connB)
+}
+
+func TestPool_DifferentUsers(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool()
+	ctx := context.Background()
+
+	a := &Tunnel{Name: "a", SSHHost: server.addr, SSHUser: "alice", HostKeyPolicy: HostKeyOff}
+	b := &Tunnel{Name: "b", SSHHost: server.addr, SSHUser: "bob", HostKeyPolicy: HostKeyOff}
+
+	connA, err := pool.acquire(ctx, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connA.release(a.Name)
+	connB, err := pool.acquire(ctx, b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connB.release(b.Name)
+
+	if connA == connB {
+		t.Error("tunnels for different users share a connection")
+	}
+}
+
+func TestPool_DifferentHostKeyPolicy(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool()
+	ctx := context.Background()
+
+	off := &Tunnel{Name: "off", SSHHost: server.addr, HostKeyPolicy: HostKeyOff}
+	strict := &Tunnel{
+		Name:            "strict",
+		SSHHost:         server.addr,
+		HostKeyPolicy:   HostKeyStrict,
+		KnownHostsFiles: []string{filepath.Join(t.TempDir(), "known_hosts")},
+	}
+
+	conn, err := pool.acquire(ctx, off, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.release(off.Name)
+
+	// The strict tunnel checks the host key itself instead of riding
+	// the unverified connection, and the server isn't in known_hosts
+	_, err = pool.acquire(ctx, strict, nil)
+	var unknown *HostKeyUnknownError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("strict acquire error = %v, want HostKeyUnknownError", err)
+	}
+	if got := server.handshakes.Load(); got != 1 {
+		t.Errorf("handshakes = %d, want 1", got)
+	}
+}
+
+func TestPool_DifferentCredentials(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool()
+	ctx := context.Background()
+
+	a := &Tunnel{Name: "a", SSHHost: server.addr, HostKeyPolicy: HostKeyOff, AuthIdentity: `keys=["~/.ssh/id_work"]`}
+	b := &Tunnel{Name: "b", SSHHost: server.addr, HostKeyPolicy: HostKeyOff, AuthIdentity: `keys=["~/.ssh/id_personal"]`}
+
+	connA, err := pool.acquire(ctx, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connA.release(a.Name)
+	connB, err := pool.acquire(ctx, b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connB.release(b.Name)
+
+	if connA == connB {
+		t.Error("tunnels with different credentials share a connection")
+	}
+	if a.connKey().id() == b.connKey().id() {
+		t.Error("tunnels with different credentials report the same connection")
+	}
+}
+
+func TestPool_DroppedConnection(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool()
+	ctx := context.Background()
+
+	a := &Tunnel{Name: "a", SSHHost: server.addr, HostKeyPolicy: HostKeyOff}
+	b := &Tunnel{Name: "b", SSHHost: server.addr, HostKeyPolicy: HostKeyOff}
+
+	conn, err := pool.acquire(ctx, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.acquire(ctx, b, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every tunnel on the connection sees it drop
+	server.dropAll()
+	waitLost(t, conn)
+	if !errors.Is(conn.err, ErrConnectionLost) {
+		t.Errorf("err = %v, want ErrConnectionLost", conn.err)
+	}
+
+	// The next tunnel gets a fresh connection
+	fresh, err := pool.acquire(ctx, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fresh.release(a.Name)
+
+	if fresh == conn {
+		t.Error("dropped connection was reused")
+	}
+	if got := server.handshakes.Load(); got != 2 {
+		t.Errorf("handshakes = %d, want 2", got)
+	}
+}
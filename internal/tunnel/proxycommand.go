@@ -0,0 +1,191 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// proxyStderrMax is how much of a ProxyCommand's stderr is kept for error messages
+const proxyStderrMax = 4096
+
+// proxyDialer connects to an SSH server by running a ProxyCommand and
+// talking to it over the command's stdin and stdout
+type proxyDialer struct {
+	command string
+	conn    *proxyConn // The most recently started command
+}
+
+// DialContext starts the command. network and addr are ignored, the
+// command already knows where to connect.
+func (d *proxyDialer) DialContext(ctx context.Context, _, _ string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	conn, err := startProxyCommand(d.command)
+	if err != nil {
+		return nil, err
+	}
+	d.conn = conn
+	return conn, nil
+}
+
+// withStderr adds the last command's stderr output to err, if it wrote any
+func (d *proxyDialer) withStderr(err error) error {
+	if d == nil || d.conn == nil {
+		return err
+	}
+	return d.conn.withStderr(err)
+}
+
+// proxyConn is a net.Conn over a running ProxyCommand
+type proxyConn struct {
+	cmd    *exec.Cmd
+	stdin  *os.File // Writes go to the command's stdin
+	stdout *os.File // Reads come from the command's stdout
+	stderr *tailBuffer
+	exited chan struct{} // Closed once the command has exited
+
+	closeOnce sync.Once
+}
+
+// startProxyCommand runs command with the user's shell, like ssh does.
+// The command gets its own process group so pipelines are cleaned up too.
+func startProxyCommand(command string) (*proxyConn, error) {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		_ = stdinR.Close()
+		_ = stdinW.Close()
+		return nil, err
+	}
+
+	c := &proxyConn{
+		cmd:    exec.Command(shell, "-c", "exec "+command),
+		stdin:  stdinW,
+		stdout: stdoutR,
+		stderr: &tailBuffer{max: proxyStderrMax},
+		exited: make(chan struct{}),
+	}
+	c.cmd.Stdin = stdinR
+	c.cmd.Stdout = stdoutW
+	c.cmd.Stderr = c.stderr
+	c.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	err = c.cmd.Start()
+
+	// The child has its own copies now
+	_ = stdinR.Close()
+	_ = stdoutW.Close()
+
+	if err != nil {
+		_ = stdinW.Close()
+		_ = stdoutR.Close()
+		return nil, fmt.Errorf("unable to run proxy command: %w", err)
+	}
+
+	go func() {
+		_ = c.cmd.Wait()
+		close(c.exited)
+	}()
+
+	return c, nil
+}
+
+// withStderr adds the command's stderr output to err, if it wrote any
+func (c *proxyConn) withStderr(err error) error {
+	msg := strings.TrimSpace(c.stderr.String())
+	if msg == "" {
+		return err
+	}
+	return fmt.Errorf("%w (proxy command: %s)", err, msg)
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	return c.stdout.Read(b)
+}
+
+func (c *proxyConn) Write(b []byte) (int, error) {
+	return c.stdin.Write(b)
+}
+
+// Close closes the pipes and stops the command. Commands that don't
+// exit after their stdin closes are killed.
+func (c *proxyConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.stdin.Close()
+		_ = c.stdout.Close()
+
+		select {
+		case <-c.exited:
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		_ = syscall.Kill(-c.cmd.Process.Pid, syscall.SIGTERM)
+		select {
+		case <-c.exited:
+		case <-time.After(2 * time.Second):
+			_ = syscall.Kill(-c.cmd.Process.Pid, syscall.SIGKILL)
+			<-c.exited
+		}
+	})
+	return nil
+}
+
+func (c *proxyConn) LocalAddr() net.Addr  { return proxyAddr{} }
+func (c *proxyConn) RemoteAddr() net.Addr { return proxyAddr{} }
+
+func (c *proxyConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *proxyConn) SetReadDeadline(t time.Time) error  { return c.stdout.SetReadDeadline(t) }
+func (c *proxyConn) SetWriteDeadline(t time.Time) error { return c.stdin.SetWriteDeadline(t) }
+
+// proxyAddr is the address of a ProxyCommand connection
+type proxyAddr struct{}
+
+func (proxyAddr) Network() string { return "proxy" }
+func (proxyAddr) String() string  { return "proxy-command" }
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
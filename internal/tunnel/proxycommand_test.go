@@ -0,0 +1,73 @@
+package tunnel
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyConn_RoundTrip(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+
+	conn, err := startProxyCommand("cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if string(buf) != "hello" {
+		t.Errorf("Read() = %q, want %q", buf, "hello")
+	}
+}
+
+func TestProxyConn_CloseStopsCommand(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+
+	// Ignores stdin closing, so has to be killed
+	conn, err := startProxyCommand("sleep 60")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_ = conn.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() did not stop the command")
+	}
+
+	select {
+	case <-conn.exited:
+	default:
+		t.Error("command still running after Close()")
+	}
+}
+
+func TestStart_ProxyCommandStderr(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+
+	err := Start(context.Background(), &Tunnel{
+		SSHHost:       "db-host:22",
+		Forwards:      []Forward{{Name: "web", Kind: KindLocal, LocalAddr: "127.0.0.1:0"}},
+		HostKeyPolicy: HostKeyOff,
+		ProxyCommand:  "echo 'proxy: connection refused' >&2; exit 1",
+	}, nil)
+
+	if err == nil || !strings.Contains(err.Error(), "proxy: connection refused") {
+		t.Errorf("Start() = %v, want error with the command's stderr", err)
+	}
+}
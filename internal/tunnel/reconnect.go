@@ -0,0 +1,82 @@
+package tunnel
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/JoshElias/gurren/internal/config"
+)
+
+// Reconnect defaults, used when a tunnel enables reconnect without tuning it
+const (
+	DefaultReconnectInitialDelay = time.Second
+	DefaultReconnectMaxDelay     = 5 * time.Minute
+	DefaultReconnectJitter       = 0.2
+)
+
+// Backoff computes exponentially growing delays between reconnect attempts
+type Backoff struct {
+	Initial time.Duration // Delay before the first attempt
+	Max     time.Duration // Upper bound on any delay
+	Jitter  float64       // Fraction (0-1) by which each delay is randomly shortened or lengthened
+}
+
+// NewBackoff builds a Backoff from a tunnel's reconnect config, filling in defaults
+func NewBackoff(rc config.ReconnectConfig) Backoff {
+	b := Backoff{
+		Initial: rc.InitialDelay,
+		Max:     rc.MaxDelay,
+		Jitter:  rc.Jitter,
+	}
+	if b.Initial <= 0 {
+		b.Initial = DefaultReconnectInitialDelay
+	}
+	if b.Max <= 0 {
+		b.Max = DefaultReconnectMaxDelay
+	}
+	if b.Max < b.Initial {
+		b.Max = b.Initial
+	}
+	if b.Jitter < 0 {
+		b.Jitter = 0
+	}
+	if b.Jitter > 1 {
+		b.Jitter = 1
+	}
+	return b
+}
+
+// Delay returns how long to wait before the given attempt (starting at 1).
+// The delay doubles each attempt up to Max, then jitter is applied.
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Initial
+	for i := 1; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+
+	if b.Jitter > 0 {
+		// Spread uniformly across [d*(1-jitter), d*(1+jitter)]
+		spread := float64(d) * b.Jitter
+		d = time.Duration(float64(d) - spread + rand.Float64()*2*spread)
+	}
+	return d
+}
+
+// shouldReconnect reports whether a dropped tunnel should be retried.
+// Host key problems need a human, so they are never retried.
+func shouldReconnect(rc config.ReconnectConfig, err error) bool {
+	if !rc.Enabled || err == nil || errors.Is(err, ErrTunnelClosed) {
+		return false
+	}
+
+	var mismatch *HostKeyMismatchError
+	var unknown *HostKeyUnknownError
+	if errors.As(err, &mismatch) || errors.As(err, &unknown) || errors.Is(err, ErrHostKeyRejected) {
+		return false
+	}
+	return true
+}
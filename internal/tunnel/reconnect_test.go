@@ -0,0 +1,81 @@
+package tunnel
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/JoshElias/gurren/internal/config"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 10 * time.Second}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := b.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	b := Backoff{Initial: 10 * time.Second, Max: time.Minute, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		got := b.Delay(1)
+		if got < 5*time.Second || got > 15*time.Second {
+			t.Fatalf("Delay(1) = %s, want within 5s-15s", got)
+		}
+	}
+}
+
+func TestNewBackoffDefaults(t *testing.T) {
+	b := NewBackoff(config.ReconnectConfig{Enabled: true, Jitter: 3})
+
+	if b.Initial != DefaultReconnectInitialDelay {
+		t.Errorf("Initial = %s, want %s", b.Initial, DefaultReconnectInitialDelay)
+	}
+	if b.Max != DefaultReconnectMaxDelay {
+		t.Errorf("Max = %s, want %s", b.Max, DefaultReconnectMaxDelay)
+	}
+	if b.Jitter != 1 {
+		t.Errorf("Jitter = %v, want clamped to 1", b.Jitter)
+	}
+}
+
+func TestShouldReconnect(t *testing.T) {
+	enabled := config.ReconnectConfig{Enabled: true}
+
+	tests := []struct {
+		name string
+		rc   config.ReconnectConfig
+		err  error
+		want bool
+	}{
+		{"connection lost", enabled, ErrConnectionLost, true},
+		{"disabled", config.ReconnectConfig{}, ErrConnectionLost, false},
+		{"stopped", enabled, ErrTunnelClosed, false},
+		{"host key mismatch", enabled, fmt.Errorf("handshake: %w", &HostKeyMismatchError{Host: "bastion:22"}), false},
+		{"host key rejected", enabled, fmt.Errorf("handshake: %w", ErrHostKeyRejected), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldReconnect(tt.rc, tt.err); got != tt.want {
+				t.Errorf("shouldReconnect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 protocol constants (RFC 1928, RFC 1929)
+const (
+	socksVersion = 0x05
+
+	socksAuthNone         = 0x00
+	socksAuthPassword     = 0x02
+	socksAuthNoAcceptable = 0xff
+
+	socksPasswordVersion = 0x01
+
+	socksCmdConnect = 0x01
+
+	socksAtypIPv4   = 0x01
+	socksAtypDomain = 0x03
+	socksAtypIPv6   = 0x04
+
+	socksRepSuccess             = 0x00
+	socksRepFailure             = 0x01
+	socksRepHostUnreachable     = 0x04
+	socksRepCommandNotSupported = 0x07
+	socksRepAddrNotSupported    = 0x08
+)
+
+// socksHandshakeTimeout bounds how long a client may take to send its request
+const socksHandshakeTimeout = 30 * time.Second
+
+// handleSocksConnection serves a single SOCKS5 client, dialing the requested
+// address with dial. If user is set, clients must authenticate with user and password.
+func handleSocksConnection(ctx context.Context, lg *Log, localConn net.Conn, dial targetDialFunc, user, password string) {
+	defer func() {
+		if err := localConn.Close(); err != nil {
+			lg.Warnf(EventCopy, "Error closing local connection: %v", err)
+		}
+	}()
+
+	_ = localConn.SetDeadline(time.Now().Add(socksHandshakeTimeout))
+	target, err := socksHandshake(localConn, user, password)
+	if err != nil {
+		lg.Warnf(EventAccept, "SOCKS handshake failed: %v", err)
+		return
+	}
+	_ = localConn.SetDeadline(time.Time{})
+
+	// Dial target through SSH
+	remoteConn, err := dial("tcp", target)
+	if err != nil {
+		lg.Warnf(EventTarget, "Failed to dial %s: %v", target, err)
+		_ = writeSocksReply(localConn, socksRepHostUnreachable)
+		return
+	}
+	defer func() {
+		if err := remoteConn.Close(); err != nil {
+			lg.Warnf(EventCopy, "Error closing remote connection: %v", err)
+		}
+	}()
+
+	if err := writeSocksReply(localConn, socksRepSuccess); err != nil {
+		return
+	}
+
+	pipe(ctx, lg, localConn, remoteConn)
+}
+
+// socksHandshake negotiates authentication and reads a CONNECT request,
+// returning the requested host:port
+func socksHandshake(conn io.ReadWriter, user, password string) (string, error) {
+	// Greeting: VER NMETHODS METHODS...
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", fmt.Errorf("reading greeting: %w", err)
+	}
+	if header[0] != socksVersion {
+		return "", fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", fmt.Errorf("reading auth methods: %w", err)
+	}
+
+	want := byte(socksAuthNone)
+	if user != "" {
+		want = socksAuthPassword
+	}
+	offered := false
+	for _, m := range methods {
+		if m == want {
+			offered = true
+			break
+		}
+	}
+	if !offered {
+		_, _ = conn.Write([]byte{socksVersion, socksAuthNoAcceptable})
+		return "", errors.New("client offered no acceptable auth method")
+	}
+	if _, err := conn.Write([]byte{socksVersion, want}); err != nil {
+		return "", err
+	}
+
+	if want == socksAuthPassword {
+		if err := socksPasswordAuth(conn, user, password); err != nil {
+			return "", err
+		}
+	}
+
+	// Request: VER CMD RSV ATYP DST.ADDR DST.PORT
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return "", fmt.Errorf("reading request: %w", err)
+	}
+	if req[0] != socksVersion {
+		return "", fmt.Errorf("unsupported SOCKS version %d", req[0])
+	}
+
+	var host string
+	switch req[3] {
+	case socksAtypIPv4, socksAtypIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if req[3] == socksAtypIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", fmt.Errorf("reading address: %w", err)
+		}
+		host = ip.String()
+	case socksAtypDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", fmt.Errorf("reading address: %w", err)
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", fmt.Errorf("reading address: %w", err)
+		}
+		host = string(domain)
+	default:
+		_ = writeSocksReply(conn, socksRepAddrNotSupported)
+		return "", fmt.Errorf("unsupported address type %d", req[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", fmt.Errorf("reading port: %w", err)
+	}
+
+	if req[1] != socksCmdConnect {
+		_ = writeSocksReply(conn, socksRepCommandNotSupported)
+		return "", fmt.Errorf("unsupported command %d", req[1])
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socksPasswordAuth runs the RFC 1929 username/password subnegotiation
+func socksPasswordAuth(conn io.ReadWriter, user, password string) error {
+	// VER ULEN UNAME PLEN PASSWD
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("reading credentials: %w", err)
+	}
+	if header[0] != socksPasswordVersion {
+		return fmt.Errorf("unsupported auth version %d", header[0])
+	}
+	gotUser := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, gotUser); err != nil {
+		return fmt.Errorf("reading credentials: %w", err)
+	}
+	plen := make([]byte, 1)
+	if _, err := io.ReadFull(conn, plen); err != nil {
+		return fmt.Errorf("reading credentials: %w", err)
+	}
+	gotPassword := make([]byte, plen[0])
+	if _, err := io.ReadFull(conn, gotPassword); err != nil {
+		return fmt.Errorf("reading credentials: %w", err)
+	}
+
+	userOK := subtle.ConstantTimeCompare(gotUser, []byte(user)) == 1
+	passwordOK := subtle.ConstantTimeCompare(gotPassword, []byte(password)) == 1
+	if !userOK || !passwordOK {
+		_, _ = conn.Write([]byte{socksPasswordVersion, socksRepFailure})
+		return errors.New("invalid username or password")
+	}
+
+	_, err := conn.Write([]byte{socksPasswordVersion, socksRepSuccess})
+	return err
+}
+
+// writeSocksReply sends a reply with an unspecified bound address
+func writeSocksReply(w io.Writer, rep byte) error {
+	_, err := w.Write([]byte{socksVersion, rep, 0x00, socksAtypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,192 @@
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// socksClient writes a scripted client conversation and collects the server's replies
+type socksClient struct {
+	in  *bytes.Buffer // what the client sends
+	out bytes.Buffer  // what the server replies
+}
+
+func (c *socksClient) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c *socksClient) Write(p []byte) (int, error) { return c.out.Write(p) }
+
+func newSocksClient(parts ...[]byte) *socksClient {
+	return &socksClient{in: bytes.NewBuffer(bytes.Join(parts, nil))}
+}
+
+func TestSocksHandshake_AddressTypes(t *testing.T) {
+	greeting := []byte{socksVersion, 1, socksAuthNone}
+
+	tests := []struct {
+		name    string
+		request []byte
+		want    string
+	}{
+		{
+			name:    "IPv4",
+			request: []byte{socksVersion, socksCmdConnect, 0, socksAtypIPv4, 10, 0, 0, 5, 0x15, 0x38},
+			want:    "10.0.0.5:5432",
+		},
+		{
+			name:    "domain",
+			request: append([]byte{socksVersion, socksCmdConnect, 0, socksAtypDomain, 11}, append([]byte("db.internal"), 0x0c, 0xea)...),
+			want:    "db.internal:3306",
+		},
+		{
+			name: "IPv6",
+			request: append([]byte{socksVersion, socksCmdConnect, 0, socksAtypIPv6},
+				append(net.ParseIP("fd00::1").To16(), 0x00, 0x50)...),
+			want: "[fd00::1]:80",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newSocksClient(greeting, tt.request)
+
+			got, err := socksHandshake(client, "", "")
+			if err != nil {
+				t.Fatalf("socksHandshake() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("socksHandshake() = %q, want %q", got, tt.want)
+			}
+			if !bytes.Equal(client.out.Bytes(), []byte{socksVersion, socksAuthNone}) {
+				t.Errorf("method reply = %v", client.out.Bytes())
+			}
+		})
+	}
+}
+
+func TestSocksHandshake_Password(t *testing.T) {
+	greeting := []byte{socksVersion, 2, socksAuthNone, socksAuthPassword}
+	request := []byte{socksVersion, socksCmdConnect, 0, socksAtypIPv4, 127, 0, 0, 1, 0, 80}
+
+	creds := func(user, password string) []byte {
+		b := []byte{socksPasswordVersion, byte(len(user))}
+		b = append(b, user...)
+		b = append(b, byte(len(password)))
+		return append(b, password...)
+	}
+
+	client := newSocksClient(greeting, creds("alice", "s3cret"), request)
+	if _, err := socksHandshake(client, "alice", "s3cret"); err != nil {
+		t.Fatalf("valid credentials rejected: %v", err)
+	}
+
+	client = newSocksClient(greeting, creds("alice", "wrong"), request)
+	if _, err := socksHandshake(client, "alice", "s3cret"); err == nil {
+		t.Fatal("invalid credentials accepted")
+	}
+	if !bytes.HasSuffix(client.out.Bytes(), []byte{socksPasswordVersion, socksRepFailure}) {
+		t.Errorf("expected auth failure reply, got %v", client.out.Bytes())
+	}
+
+	// A client that can't authenticate is refused outright
+	client = newSocksClient([]byte{socksVersion, 1, socksAuthNone})
+	if _, err := socksHandshake(client, "alice", "s3cret"); err == nil {
+		t.Fatal("client without password auth accepted")
+	}
+	if !bytes.Equal(client.out.Bytes(), []byte{socksVersion, socksAuthNoAcceptable}) {
+		t.Errorf("expected no acceptable methods reply, got %v", client.out.Bytes())
+	}
+}
+
+func TestSocksHandshake_UnsupportedCommand(t *testing.T) {
+	// BIND (0x02) is not supported
+	client := newSocksClient(
+		[]byte{socksVersion, 1, socksAuthNone},
+		[]byte{socksVersion, 0x02, 0, socksAtypIPv4, 127, 0, 0, 1, 0, 80},
+	)
+
+	if _, err := socksHandshake(client, "", ""); err == nil {
+		t.Fatal("BIND request accepted")
+	}
+	reply := client.out.Bytes()[2:]
+	if len(reply) < 2 || reply[1] != socksRepCommandNotSupported {
+		t.Errorf("expected command not supported reply, got %v", reply)
+	}
+}
+
+func TestHandleSocksConnection(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	// The "remote" end echoes back what it receives
+	var dialed string
+	dial := func(network, addr string) (net.Conn, error) {
+		dialed = addr
+		local, remote := net.Pipe()
+		go func() {
+			_, _ = io.Copy(remote, remote)
+		}()
+		return local, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		handleSocksConnection(context.Background(), nil, serverConn, dial, "", "")
+		close(done)
+	}()
+
+	mustWrite := func(b []byte) {
+		if _, err := clientConn.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustRead := func(n int) []byte {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(clientConn, b); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	mustWrite([]byte{socksVersion, 1, socksAuthNone})
+	mustRead(2)
+	mustWrite([]byte{socksVersion, socksCmdConnect, 0, socksAtypIPv4, 10, 1, 2, 3, 0x1f, 0x90})
+	if reply := mustRead(10); reply[1] != socksRepSuccess {
+		t.Fatalf("CONNECT reply = %v, want success", reply)
+	}
+	if dialed != "10.1.2.3:8080" {
+		t.Errorf("dialed %q, want 10.1.2.3:8080", dialed)
+	}
+
+	mustWrite([]byte("ping"))
+	if got := mustRead(4); string(got) != "ping" {
+		t.Errorf("echo = %q, want ping", got)
+	}
+
+	_ = clientConn.Close()
+	<-done
+}
+
+func TestHandleSocksConnection_DialError(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	dial := func(network, addr string) (net.Conn, error) {
+		return nil, errors.New("connect failed")
+	}
+	go handleSocksConnection(context.Background(), nil, serverConn, dial, "", "")
+
+	_, _ = clientConn.Write([]byte{socksVersion, 1, socksAuthNone})
+	_, _ = io.ReadFull(clientConn, make([]byte, 2))
+	_, _ = clientConn.Write([]byte{socksVersion, socksCmdConnect, 0, socksAtypIPv4, 10, 1, 2, 3, 0, 80})
+
+	reply := make([]byte, 10)
+	if _, err := io.ReadFull(clientConn, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply[1] != socksRepHostUnreachable {
+		t.Errorf("reply = %v, want host unreachable", reply)
+	}
+}
@@ -9,6 +9,11 @@ const (
 	StateConnecting   State = "connecting"
 	StateConnected    State = "connected"
 	StateError        State = "error"
+	StateReconnecting State = "reconnecting" // waiting to retry after the connection dropped
+
+	// StateHostKeyMismatch means the server presented a key that differs from
+	// known_hosts. It is kept apart from StateError since it may indicate a MITM.
+	StateHostKeyMismatch State = "host-key-mismatch"
 )
 
 // String returns the string representation of the state
@@ -16,7 +21,12 @@ func (s State) String() string {
 	return string(s)
 }
 
-// IsActive returns true if the tunnel is connecting or connected
+// IsError returns true if the tunnel stopped because of a failure
+func (s State) IsError() bool {
+	return s == StateError || s == StateHostKeyMismatch
+}
+
+// IsActive returns true if the tunnel is connecting, connected or waiting to reconnect
 func (s State) IsActive() bool {
-	return s == StateConnecting || s == StateConnected
+	return s == StateConnecting || s == StateConnected || s == StateReconnecting
 }
@@ -0,0 +1,160 @@
+package tunnel
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Stats counts a tunnel's traffic and connections across reconnects.
+// The zero value is ready to use, and a nil *Stats counts nothing.
+type Stats struct {
+	bytesIn      atomic.Uint64
+	bytesOut     atomic.Uint64
+	activeConns  atomic.Int64
+	totalConns   atomic.Uint64
+	failedDials  atomic.Uint64
+	lastActivity atomic.Int64 // Unix nanoseconds
+
+	handshakes    atomic.Uint64
+	handshakeTime atomic.Int64 // Nanoseconds, summed over handshakes
+	lastHandshake atomic.Int64 // Nanoseconds
+	authFailures  atomic.Uint64
+}
+
+// StatsSnapshot is a copy of a tunnel's counters at one point in time
+type StatsSnapshot struct {
+	BytesIn      uint64    // Received through the SSH connection
+	BytesOut     uint64    // Sent through the SSH connection
+	ActiveConns  int64     // Connections being forwarded now
+	TotalConns   uint64    // Connections accepted
+	FailedDials  uint64    // Connections whose target couldn't be reached
+	LastActivity time.Time // When a connection was last accepted or carried data
+
+	Handshakes    uint64        // SSH connections made to the server
+	HandshakeTime time.Duration // Time spent in them, summed
+	LastHandshake time.Duration // Time the latest one took
+	AuthFailures  uint64        // Connections the server or a jump host refused to authenticate
+}
+
+// Snapshot returns the current counters
+func (s *Stats) Snapshot() StatsSnapshot {
+	if s == nil {
+		return StatsSnapshot{}
+	}
+
+	snap := StatsSnapshot{
+		BytesIn:     s.bytesIn.Load(),
+		BytesOut:    s.bytesOut.Load(),
+		ActiveConns: s.activeConns.Load(),
+		TotalConns:  s.totalConns.Load(),
+		FailedDials: s.failedDials.Load(),
+
+		Handshakes:    s.handshakes.Load(),
+		HandshakeTime: time.Duration(s.handshakeTime.Load()),
+		LastHandshake: time.Duration(s.lastHandshake.Load()),
+		AuthFailures:  s.authFailures.Load(),
+	}
+	if last := s.lastActivity.Load(); last != 0 {
+		snap.LastActivity = time.Unix(0, last)
+	}
+	return snap
+}
+
+// touch records activity now
+func (s *Stats) touch() {
+	s.lastActivity.Store(time.Now().UnixNano())
+}
+
+// track counts an accepted connection and the bytes it carries until
+// the returned func is called. inbound is true for connections accepted
+// on the SSH server, whose reads arrive through the SSH connection.
+func (s *Stats) track(conn net.Conn, inbound bool) (net.Conn, func()) {
+	if s == nil {
+		return conn, func() {}
+	}
+
+	s.totalConns.Add(1)
+	s.activeConns.Add(1)
+	s.touch()
+
+	c := &countedConn{Conn: conn, stats: s, read: &s.bytesOut, written: &s.bytesIn}
+	if inbound {
+		c.read, c.written = &s.bytesIn, &s.bytesOut
+	}
+	return c, func() { s.activeConns.Add(-1) }
+}
+
+// countDials wraps dial to count the connections it fails to open
+func (s *Stats) countDials(dial targetDialFunc) targetDialFunc {
+	if s == nil {
+		return dial
+	}
+	return func(network, addr string) (net.Conn, error) {
+		conn, err := dial(network, addr)
+		if err != nil {
+			s.failedDials.Add(1)
+		}
+		return conn, err
+	}
+}
+
+// handshake records how long connecting to the SSH server took, up to
+// it presenting its host key. Auth is left out since it may wait on a
+// prompt.
+func (s *Stats) handshake(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.handshakes.Add(1)
+	s.handshakeTime.Add(int64(d))
+	s.lastHandshake.Store(int64(d))
+}
+
+// authFailure counts a connection that failed to authenticate
+func (s *Stats) authFailure() {
+	if s != nil {
+		s.authFailures.Add(1)
+	}
+}
+
+// countedConn adds the bytes read and written to a tunnel's counters
+type countedConn struct {
+	net.Conn
+	stats   *Stats
+	read    *atomic.Uint64
+	written *atomic.Uint64
+}
+
+func (c *countedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.read.Add(uint64(n))
+		c.stats.touch()
+	}
+	return n, err
+}
+
+func (c *countedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.written.Add(uint64(n))
+		c.stats.touch()
+	}
+	return n, err
+}
+
+// FormatBytes formats a byte count for display, e.g. "1.5 MiB"
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
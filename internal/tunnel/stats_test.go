@@ -0,0 +1,106 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestStats_Track(t *testing.T) {
+	var stats Stats
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	// A local connection: what it sends goes out through SSH
+	conn, done := stats.track(server, false)
+	go func() {
+		_, _ = client.Write([]byte("request"))
+		_, _ = io.ReadFull(client, make([]byte, 8))
+	}()
+
+	if _, err := io.ReadFull(conn, make([]byte, 7)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("response")); err != nil {
+		t.Fatal(err)
+	}
+
+	snap := stats.Snapshot()
+	if snap.BytesOut != 7 || snap.BytesIn != 8 {
+		t.Errorf("BytesOut, BytesIn = %d, %d, want 7, 8", snap.BytesOut, snap.BytesIn)
+	}
+	if snap.ActiveConns != 1 || snap.TotalConns != 1 {
+		t.Errorf("ActiveConns, TotalConns = %d, %d, want 1, 1", snap.ActiveConns, snap.TotalConns)
+	}
+	if snap.LastActivity.IsZero() {
+		t.Error("LastActivity not set")
+	}
+
+	done()
+	if got := stats.Snapshot().ActiveConns; got != 0 {
+		t.Errorf("ActiveConns after close = %d, want 0", got)
+	}
+}
+
+func TestStats_CountDials(t *testing.T) {
+	var stats Stats
+
+	dial := stats.countDials(func(network, addr string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	})
+	_, _ = dial("tcp", "db:5432")
+	_, _ = dial("tcp", "db:5432")
+
+	if got := stats.Snapshot().FailedDials; got != 2 {
+		t.Errorf("FailedDials = %d, want 2", got)
+	}
+
+	// Nil stats count nothing
+	var none *Stats
+	if _, err := none.countDials(dial)("tcp", "db:5432"); err == nil {
+		t.Error("dial error lost")
+	}
+	if snap := none.Snapshot(); snap != (StatsSnapshot{}) {
+		t.Errorf("nil Snapshot() = %+v, want zero", snap)
+	}
+}
+
+func TestStats_Handshake(t *testing.T) {
+	server := newTestServer(t)
+	tun := &Tunnel{Name: "db", SSHHost: server.addr, HostKeyPolicy: HostKeyOff, Stats: &Stats{}}
+
+	conn, err := dialConn(context.Background(), tun, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.release(tun.Name)
+
+	snap := tun.Stats.Snapshot()
+	if snap.Handshakes != 1 {
+		t.Errorf("Handshakes = %d, want 1", snap.Handshakes)
+	}
+	if snap.LastHandshake <= 0 || snap.HandshakeTime != snap.LastHandshake {
+		t.Errorf("LastHandshake, HandshakeTime = %v, %v, want the same positive time", snap.LastHandshake, snap.HandshakeTime)
+	}
+	if snap.AuthFailures != 0 {
+		t.Errorf("AuthFailures = %d, want 0", snap.AuthFailures)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[uint64]string{
+		0:       "0 B",
+		1023:    "1023 B",
+		1536:    "1.5 KiB",
+		5 << 20: "5.0 MiB",
+		3 << 30: "3.0 GiB",
+	}
+	for n, want := range tests {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -6,9 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -16,109 +17,402 @@ import (
 // ErrTunnelClosed is returned when the tunnel is closed via context cancellation
 var ErrTunnelClosed = errors.New("tunnel closed")
 
+// ErrConnectionLost is returned when the SSH connection drops while the tunnel is up
+var ErrConnectionLost = errors.New("connection to SSH server lost")
+
+// dialTimeout bounds how long connecting to the SSH server may take
+const dialTimeout = 30 * time.Second
+
+// Phase is the step of establishing a tunnel that failed
+type Phase string
+
+const (
+	PhaseDial      Phase = "dial"      // TCP connection to the SSH server
+	PhaseHandshake Phase = "handshake" // SSH protocol and host key verification
+	PhaseAuth      Phase = "auth"      // User authentication
+	PhaseListen    Phase = "listen"    // Binding the local address
+)
+
+// Error is a failure to establish a tunnel, tagged with the phase it failed in
+type Error struct {
+	Phase   Phase
+	Forward string // Name of the forward that failed (PhaseListen only)
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// FailedForward returns the name of the forward that failed to start,
+// or "" if err is not about a single forward
+func FailedForward(err error) string {
+	var tErr *Error
+	if errors.As(err, &tErr) {
+		return tErr.Forward
+	}
+	return ""
+}
+
+// ErrorPhase returns the phase a tunnel failed in, or "" if err is not an *Error
+func ErrorPhase(err error) Phase {
+	var tErr *Error
+	if errors.As(err, &tErr) {
+		return tErr.Phase
+	}
+	return ""
+}
+
+// handshakePhase tells authentication failures apart from other handshake errors.
+// x/crypto/ssh doesn't export a type for them, so match its message.
+func handshakePhase(err error) Phase {
+	if strings.Contains(err.Error(), "unable to authenticate") {
+		return PhaseAuth
+	}
+	return PhaseHandshake
+}
+
+// Kind is the kind of forwarding a tunnel does
+type Kind string
+
+const (
+	KindLocal  Kind = "local"  // Forward a local port to a fixed remote address (ssh -L)
+	KindSocks  Kind = "socks"  // Run a SOCKS5 proxy on the local address (ssh -D)
+	KindRemote Kind = "remote" // Listen on the SSH server and forward to a local address (ssh -R)
+)
+
+// ParseKind parses a tunnel type from config. Empty means KindLocal.
+func ParseKind(s string) (Kind, error) {
+	switch Kind(s) {
+	case "", KindLocal:
+		return KindLocal, nil
+	case KindSocks, KindRemote:
+		return Kind(s), nil
+	default:
+		return "", fmt.Errorf("invalid tunnel type %q (want local, socks or remote)", s)
+	}
+}
+
+// Hop is a jump host (ProxyJump) the tunnel connects through on the way
+// to its SSH server. Each hop authenticates on its own.
+type Hop struct {
+	Name            string           // Host as configured, used in error messages
+	SSHHost         string           // SSH server address (host:port)
+	SSHUser         string           // SSH username
+	ProxyCommand    string           // Command to connect through, only used for the first hop
+	KnownHostsFiles []string         // known_hosts files for this hop
+	AuthMethods     []ssh.AuthMethod // Auth methods for this hop
+	AuthIdentity    string           // Identifies the credentials in AuthMethods
+	AuthDone        func()           // Called after each handshake with AuthMethods, e.g. to close the agent connection (optional)
+}
+
+// Forward is one port forward carried by a tunnel
+type Forward struct {
+	Name       string // Identifies the forward in errors and status
+	Kind       Kind   // What kind of forwarding this is
+	LocalAddr  string // Local bind address, or the local target for KindRemote (host:port)
+	RemoteAddr string // Remote endpoint to tunnel to, or the server-side bind address for KindRemote (host:port)
+}
+
 // Tunnel represents an SSH tunnel configuration.
 type Tunnel struct {
-	SSHHost    string // SSH server address (host:port)
-	SSHUser    string // SSH username
-	RemoteAddr string // Remote endpoint to tunnel to (host:port)
-	LocalAddr  string // Local bind address (host:port)
+	SSHHost         string        // SSH server address (host:port)
+	SSHUser         string        // SSH username
+	Forwards        []Forward     // Port forwards, started and stopped together
+	HostKeyPolicy   HostKeyPolicy // How unknown or changed host keys are handled
+	KnownHostsFiles []string      // known_hosts files; the first receives newly accepted keys
+	Jumps           []Hop         // Jump hosts to connect through, in order
+	AuthIdentity    string        // Identifies the credentials of the auth methods; connections are only shared with the same identity
+	AuthDone        func()        // Called after each handshake with the auth methods, e.g. to close the agent connection (optional)
+	ProxyCommand    string        // Command whose stdin/stdout reach the SSH server, instead of dialing (ignored with Jumps)
+
+	Name  string // Tunnel name, to track which tunnels share a connection
+	Pool  *Pool  // Shares the SSH connection with other tunnels (optional)
+	Stats *Stats // Counts traffic and connections (optional)
+	Log   *Log   // Records the tunnel's events (optional)
+
+	KeepaliveInterval time.Duration // How often to check the server is alive (0 disables)
+	KeepaliveCountMax int           // Unanswered keepalives before the connection is considered dead
+
+	SocksUser     string // Username required by SOCKS proxy forwards (optional)
+	SocksPassword string // Password required by SOCKS proxy forwards (optional)
+
+	ConfirmHostKey HostKeyConfirmFunc         // Asks the user about unknown host keys (ask policy)
+	OnReady        func(listenAddrs []string) // Called once connected and listening, with each forward's bound address
 }
 
-// Start establishes the SSH tunnel and listens for local connections.
+// Start establishes the SSH tunnel and listens for connections on every
+// forward. If any forward can't listen, none are started.
 // This function blocks until the context is cancelled or an error occurs.
 func Start(ctx context.Context, t *Tunnel, authMethods []ssh.AuthMethod) error {
-	config := &ssh.ClientConfig{
-		User:            t.SSHUser,
-		Auth:            authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // TODO: implement proper host key verification
+	// Connect to the SSH server, sharing a connection if the tunnel has a pool
+	var conn *sshConn
+	var err error
+	if t.Pool != nil {
+		conn, err = t.Pool.acquire(ctx, t, authMethods)
+	} else {
+		conn, err = dialConn(ctx, t, authMethods, nil)
 	}
-
-	// Connect to SSH server
-	sshClient, err := ssh.Dial("tcp", t.SSHHost, config)
 	if err != nil {
-		return fmt.Errorf("unable to connect to SSH server %s: %w", t.SSHHost, err)
+		return err
 	}
-	defer func() {
-		if err := sshClient.Close(); err != nil {
-			log.Printf("Warning: error closing SSH client: %v", err)
+	defer conn.release(t.Name)
+
+	// Start listeners - on the SSH server for remote forwards, locally otherwise
+	listeners := make([]net.Listener, 0, len(t.Forwards))
+	closeListeners := func() {
+		for _, l := range listeners {
+			if err := l.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+				t.Log.Warnf(EventListen, "Error closing listener: %v", err)
+			}
 		}
-	}()
+	}
+	for _, f := range t.Forwards {
+		listener, err := t.listen(ctx, conn.client, f)
+		if err != nil {
+			closeListeners()
+			if len(t.Forwards) > 1 {
+				err = fmt.Errorf("forward %s: %w", f.Name, err)
+			}
+			return &Error{Phase: PhaseListen, Forward: f.Name, Err: err}
+		}
+		listeners = append(listeners, listener)
+	}
 
-	log.Printf("Connected to %s", t.SSHHost)
+	// The bound address differs from the configured one when port 0 was asked for
+	listenAddrs := make([]string, len(listeners))
+	for i, f := range t.Forwards {
+		listenAddrs[i] = listeners[i].Addr().String()
 
-	// Start local listener
-	lc := net.ListenConfig{}
-	listener, err := lc.Listen(ctx, "tcp", t.LocalAddr)
-	if err != nil {
-		return fmt.Errorf("unable to listen on %s: %w", t.LocalAddr, err)
-	}
-	defer func() {
-		if err := listener.Close(); err != nil {
-			log.Printf("Warning: error closing listener: %v", err)
+		switch f.Kind {
+		case KindSocks:
+			t.Log.Infof(EventListen, "SOCKS proxy active: %s (via %s)", listenAddrs[i], t.SSHHost)
+		case KindRemote:
+			t.Log.Infof(EventListen, "Remote forward active: %s on %s -> %s", listenAddrs[i], t.SSHHost, f.LocalAddr)
+		default:
+			t.Log.Infof(EventListen, "Tunnel active: %s -> %s (via %s)", listenAddrs[i], f.RemoteAddr, t.SSHHost)
 		}
-	}()
+	}
 
-	log.Printf("Tunnel active: %s -> %s (via %s)", t.LocalAddr, t.RemoteAddr, t.SSHHost)
+	if t.OnReady != nil {
+		t.OnReady(listenAddrs)
+	}
 
 	// Track active connections for graceful shutdown
 	var wg sync.WaitGroup
 	connCtx, connCancel := context.WithCancel(ctx)
 	defer connCancel()
 
-	// Handle context cancellation
-	go func() {
-		<-ctx.Done()
-		_ = listener.Close()
-	}()
+	stopping := make(chan struct{})
+	var serving sync.WaitGroup
+	for i, f := range t.Forwards {
+		serving.Go(func() {
+			t.serve(connCtx, &wg, conn.client, f, listeners[i], stopping)
+		})
+	}
+
+	// Stop accepting when the context is cancelled or the SSH connection dies
+	select {
+	case <-ctx.Done():
+	case <-conn.lost:
+	}
+	close(stopping)
+	closeListeners()
+	serving.Wait()
+
+	if ctx.Err() != nil {
+		// Wait for active connections to finish
+		wg.Wait()
+		return ErrTunnelClosed
+	}
+
+	connCancel()
+	wg.Wait()
+	return conn.err
+}
+
+// listen opens the listener for a forward
+func (t *Tunnel) listen(ctx context.Context, sshClient *ssh.Client, f Forward) (net.Listener, error) {
+	if f.Kind == KindRemote {
+		listener, err := sshClient.Listen("tcp", f.RemoteAddr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to listen on %s on %s: %w", f.RemoteAddr, t.SSHHost, err)
+		}
+		return listener, nil
+	}
+
+	lc := net.ListenConfig{}
+	listener, err := lc.Listen(ctx, "tcp", f.LocalAddr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %w", f.LocalAddr, err)
+	}
+	return listener, nil
+}
+
+// serve accepts connections for a forward until stopping is closed
+func (t *Tunnel) serve(ctx context.Context, wg *sync.WaitGroup, sshClient *ssh.Client, f Forward, listener net.Listener, stopping <-chan struct{}) {
+	// Targets are reached through SSH, except for remote forwards
+	dial := t.Stats.countDials(func(network, addr string) (net.Conn, error) {
+		if f.Kind == KindRemote {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		}
+		return sshClient.Dial(network, addr)
+	})
 
-	// Accept connections
 	for {
-		localConn, err := listener.Accept()
+		c, err := listener.Accept()
 		if err != nil {
-			// Check if we're shutting down
-			if ctx.Err() != nil {
-				// Wait for active connections to finish
-				wg.Wait()
-				return ErrTunnelClosed
+			select {
+			case <-stopping:
+				return
+			default:
 			}
-			log.Printf("Failed to accept connection: %v", err)
+			t.Log.Warnf(EventAccept, "Failed to accept connection: %v", err)
 			continue
 		}
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			handleConnection(connCtx, sshClient, localConn, t.RemoteAddr)
-		}()
+		t.Log.Debugf(EventAccept, "Accepted connection from %s on %s", c.RemoteAddr(), listener.Addr())
+		wg.Go(func() {
+			c, done := t.Stats.track(c, f.Kind == KindRemote)
+			defer done()
+
+			switch f.Kind {
+			case KindSocks:
+				handleSocksConnection(ctx, t.Log, c, dial, t.SocksUser, t.SocksPassword)
+			case KindRemote:
+				// Accepted on the server side; the target is local
+				handleRemoteConnection(ctx, t.Log, dial, c, f.LocalAddr)
+			default:
+				handleConnection(ctx, t.Log, dial, c, f.RemoteAddr)
+			}
+		})
+	}
+}
+
+// dialFunc opens a connection, directly or through a jump host
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// targetDialFunc opens a connection to where a forward leads, e.g. the
+// address a SOCKS client asked for
+type targetDialFunc func(network, addr string) (net.Conn, error)
+
+// connect dials an SSH server and completes the handshake. It also
+// returns how long the server took to present its host key, from the
+// start of the dial. Errors are tagged with the phase they happened in.
+func (t *Tunnel) connect(ctx context.Context, dial dialFunc, addr, user string, authMethods []ssh.AuthMethod, knownHostsFiles []string) (*ssh.Client, time.Duration, error) {
+	verifier, err := newHostKeyVerifier(ctx, t.HostKeyPolicy, knownHostsFiles, t.ConfirmHostKey)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start := time.Now()
+	var handshake time.Duration
+	config := &ssh.ClientConfig{
+		User: user,
+		Auth: authMethods,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			handshake = time.Since(start)
+			return verifier.Callback(hostname, remote, key)
+		},
+		HostKeyAlgorithms: verifier.Algorithms(addr),
+	}
+
+	t.Log.Infof(EventDial, "Connecting to %s as %s", addr, user)
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	conn, err := dial(dialCtx, "tcp", addr)
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, ErrTunnelClosed
+		}
+		return nil, 0, &Error{Phase: PhaseDial, Err: fmt.Errorf("unable to connect to SSH server %s: %w", addr, err)}
+	}
+
+	// Abort the handshake if the tunnel is stopped while it is in progress
+	// (e.g. while waiting for a passphrase)
+	stopAbort := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	stopAbort()
+	if err != nil {
+		_ = conn.Close()
+		if ctx.Err() != nil {
+			return nil, 0, ErrTunnelClosed
+		}
+		err = fmt.Errorf("unable to connect to SSH server %s: %w", addr, err)
+		if pc, ok := conn.(*proxyConn); ok {
+			// Close has waited for the command to exit, so its stderr is complete
+			err = pc.withStderr(err)
+		}
+		phase := handshakePhase(err)
+		if phase == PhaseAuth {
+			t.Stats.authFailure()
+		}
+		return nil, 0, &Error{Phase: phase, Err: err}
 	}
+	return ssh.NewClient(sshConn, chans, reqs), handshake, nil
 }
 
-func handleConnection(ctx context.Context, sshClient *ssh.Client, localConn net.Conn, remoteAddr string) {
+// handleConnection forwards a local connection to the remote address
+func handleConnection(ctx context.Context, lg *Log, dial targetDialFunc, localConn net.Conn, remoteAddr string) {
 	defer func() {
 		if err := localConn.Close(); err != nil {
-			log.Printf("Warning: error closing local connection: %v", err)
+			lg.Warnf(EventCopy, "Error closing local connection: %v", err)
 		}
 	}()
 
 	// Dial remote through SSH
-	remoteConn, err := sshClient.Dial("tcp", remoteAddr)
+	remoteConn, err := dial("tcp", remoteAddr)
 	if err != nil {
-		log.Printf("Failed to dial remote %s: %v", remoteAddr, err)
+		lg.Warnf(EventTarget, "Failed to dial remote %s: %v", remoteAddr, err)
 		return
 	}
 	defer func() {
 		if err := remoteConn.Close(); err != nil {
-			log.Printf("Warning: error closing remote connection: %v", err)
+			lg.Warnf(EventCopy, "Error closing remote connection: %v", err)
+		}
+	}()
+
+	pipe(ctx, lg, localConn, remoteConn)
+}
+
+// handleRemoteConnection forwards a connection accepted on the SSH server
+// to the local target address
+func handleRemoteConnection(ctx context.Context, lg *Log, dial targetDialFunc, remoteConn net.Conn, localAddr string) {
+	defer func() {
+		if err := remoteConn.Close(); err != nil {
+			lg.Warnf(EventCopy, "Error closing remote connection: %v", err)
+		}
+	}()
+
+	localConn, err := dial("tcp", localAddr)
+	if err != nil {
+		lg.Warnf(EventTarget, "Failed to dial local %s: %v", localAddr, err)
+		return
+	}
+	defer func() {
+		if err := localConn.Close(); err != nil {
+			lg.Warnf(EventCopy, "Error closing local connection: %v", err)
 		}
 	}()
 
+	pipe(ctx, lg, localConn, remoteConn)
+}
+
+// pipe copies data both ways between a local and remote connection until
+// either side closes or ctx is cancelled
+func pipe(ctx context.Context, lg *Log, localConn, remoteConn net.Conn) {
 	// Bidirectional copy
 	done := make(chan struct{}, 2)
 
 	go func() {
 		_, err := io.Copy(remoteConn, localConn)
 		if err != nil && ctx.Err() == nil {
-			log.Printf("Error copying to remote: %v", err)
+			lg.Warnf(EventCopy, "Error copying to remote: %v", err)
 		}
 		done <- struct{}{}
 	}()
@@ -126,7 +420,7 @@ func handleConnection(ctx context.Context, sshClient *ssh.Client, localConn net.
 	go func() {
 		_, err := io.Copy(localConn, remoteConn)
 		if err != nil && ctx.Err() == nil {
-			log.Printf("Error copying from remote: %v", err)
+			lg.Warnf(EventCopy, "Error copying from remote: %v", err)
 		}
 		done <- struct{}{}
 	}()
@@ -0,0 +1,181 @@
+package tunnel
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStart_DialError(t *testing.T) {
+	// Grab a free port, then close it so nothing is listening
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	err = Start(context.Background(), &Tunnel{
+		SSHHost:       addr,
+		Forwards:      []Forward{{Name: "web", Kind: KindLocal, LocalAddr: "127.0.0.1:0"}},
+		HostKeyPolicy: HostKeyOff,
+	}, nil)
+
+	var tErr *Error
+	if !errors.As(err, &tErr) {
+		t.Fatalf("Start() = %v, want *Error", err)
+	}
+	if tErr.Phase != PhaseDial {
+		t.Errorf("Phase = %q, want %q", tErr.Phase, PhaseDial)
+	}
+}
+
+func TestStart_JumpDialError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	err = Start(context.Background(), &Tunnel{
+		SSHHost:       "db-host:22",
+		Forwards:      []Forward{{Name: "web", Kind: KindLocal, LocalAddr: "127.0.0.1:0"}},
+		HostKeyPolicy: HostKeyOff,
+		Jumps:         []Hop{{Name: "bastion-a", SSHHost: addr}},
+	}, nil)
+
+	if got := ErrorPhase(err); got != PhaseDial {
+		t.Errorf("ErrorPhase() = %q, want %q", got, PhaseDial)
+	}
+	if err == nil || !strings.Contains(err.Error(), "jump host 1 (bastion-a)") {
+		t.Errorf("Start() = %v, want error naming the failed hop", err)
+	}
+}
+
+func TestStart_ForwardListenError(t *testing.T) {
+	server := newTestServer(t)
+
+	// Hold a port so the second forward can't bind it
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	ready := false
+	err = Start(context.Background(), &Tunnel{
+		SSHHost:       server.addr,
+		HostKeyPolicy: HostKeyOff,
+		Forwards: []Forward{
+			{Name: "postgres", Kind: KindLocal, LocalAddr: "127.0.0.1:0", RemoteAddr: "db:5432"},
+			{Name: "redis", Kind: KindLocal, LocalAddr: busy.Addr().String(), RemoteAddr: "cache:6379"},
+		},
+		OnReady: func([]string) { ready = true },
+	}, nil)
+
+	if got := ErrorPhase(err); got != PhaseListen {
+		t.Errorf("ErrorPhase() = %q, want %q", got, PhaseListen)
+	}
+	if got := FailedForward(err); got != "redis" {
+		t.Errorf("FailedForward() = %q, want %q", got, "redis")
+	}
+	if ready {
+		t.Error("OnReady called although a forward failed")
+	}
+}
+
+func TestStart_RemoteForward(t *testing.T) {
+	server := newTestServer(t)
+
+	// The local target echoes what it is sent
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	go func() {
+		for {
+			c, err := target.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_, _ = io.Copy(c, c)
+			}()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan []string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- Start(ctx, &Tunnel{
+			SSHHost:       server.addr,
+			HostKeyPolicy: HostKeyOff,
+			Forwards:      []Forward{{Name: "web", Kind: KindRemote, LocalAddr: target.Addr().String(), RemoteAddr: "127.0.0.1:0"}},
+			OnReady:       func(listenAddrs []string) { ready <- listenAddrs },
+		}, nil)
+	}()
+
+	var listenAddrs []string
+	select {
+	case listenAddrs = <-ready:
+	case err := <-done:
+		t.Fatalf("Start() = %v before the forward was ready", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("remote forward not ready")
+	}
+
+	// A connection to the server's port reaches the local target
+	c, err := net.Dial("tcp", listenAddrs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Errorf("read %q, %v through the remote forward, want %q", line, err, "ping\n")
+	}
+	_ = c.Close()
+
+	// Stopping cancels the forward on the server
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrTunnelClosed) {
+			t.Errorf("Start() = %v, want ErrTunnelClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start() didn't return after stopping")
+	}
+	if got := server.cancels.Load(); got != 1 {
+		t.Errorf("server got %d cancel-tcpip-forward requests, want 1", got)
+	}
+	if c, err := net.Dial("tcp", listenAddrs[0]); err == nil {
+		_ = c.Close()
+		t.Error("server still listening after the tunnel stopped")
+	}
+}
+
+func TestHandshakePhase(t *testing.T) {
+	authErr := errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none publickey], no supported methods remain")
+	if got := handshakePhase(authErr); got != PhaseAuth {
+		t.Errorf("handshakePhase(auth failure) = %q, want %q", got, PhaseAuth)
+	}
+
+	kexErr := errors.New("ssh: handshake failed: ssh: no common algorithm for key exchange")
+	if got := handshakePhase(kexErr); got != PhaseHandshake {
+		t.Errorf("handshakePhase(kex failure) = %q, want %q", got, PhaseHandshake)
+	}
+}